@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"bytes"
 	"fmt"
 
 	pluginapi "github.com/mattermost/mattermost-plugin-api"
@@ -36,6 +37,11 @@ func New(client *pluginapi.Client) (*Bot, error) {
 	}, nil
 }
 
+// ID returns the user ID of the bot.
+func (b *Bot) ID() string {
+	return b.botID
+}
+
 func (b *Bot) SendEphemeralPost(channelID string, userID string, msg string) error {
 	post := &model.Post{
 		UserId:    b.botID,
@@ -60,6 +66,51 @@ func (b *Bot) SendDirectPost(userID string, msg string) error {
 	return b.client.Post.CreatePost(post)
 }
 
+// SendPostWithFile uploads a file and posts it to the channel with the message.
+func (b *Bot) SendPostWithFile(channelID string, msg string, fileName string, data []byte) error {
+	info, err := b.client.File.Upload(bytes.NewReader(data), fileName, channelID)
+	if err != nil {
+		return fmt.Errorf("bot cannot upload file: %w", err)
+	}
+
+	post := &model.Post{
+		UserId:    b.botID,
+		ChannelId: channelID,
+		Message:   msg,
+		FileIds:   model.StringArray{info.Id},
+	}
+	return b.client.Post.CreatePost(post)
+}
+
+// SendDirectPostWithFile uploads a file and sends it to the user as a direct message.
+func (b *Bot) SendDirectPostWithFile(userID string, msg string, fileName string, data []byte) error {
+	channel, err := b.client.Channel.GetDirect(userID, b.botID)
+	if err != nil {
+		return fmt.Errorf("bot cannot send direct message: %w", err)
+	}
+	return b.SendPostWithFile(channel.Id, msg, fileName, data)
+}
+
+// SendThread posts the first message to the channel and the remaining messages as replies to it.
+func (b *Bot) SendThread(channelID string, msgs []string) error {
+	var rootID string
+	for _, msg := range msgs {
+		post := &model.Post{
+			UserId:    b.botID,
+			ChannelId: channelID,
+			RootId:    rootID,
+			Message:   msg,
+		}
+		if err := b.client.Post.CreatePost(post); err != nil {
+			return err
+		}
+		if rootID == "" {
+			rootID = post.Id
+		}
+	}
+	return nil
+}
+
 func (b *Bot) SendPost(channelID string, msg string) error {
 	post := &model.Post{
 		UserId:    b.botID,
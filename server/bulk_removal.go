@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+	maxBulkRemovalUsers    = 1000
+	maxBulkRemovalBodySize = 1 << 20 // 1MB, ample for maxBulkRemovalUsers users
+	bulkRemovalFileKey     = "file"
+)
+
+// BulkPayload lists the users to remove. Each list may be combined with the others.
+type BulkPayload struct {
+	UserIDs   []string `json:"user_ids"`
+	Usernames []string `json:"usernames"`
+	Emails    []string `json:"emails"`
+	Users     []string `json:"users"` // user IDs, usernames or emails
+}
+
+// UserRemovalResult is the outcome of removing one user.
+type UserRemovalResult struct {
+	User              string       `json:"user"` // as given in the request
+	UserID            string       `json:"user_id,omitempty"`
+	Success           bool         `json:"success"`
+	Error             string       `json:"error,omitempty"`
+	Plan              *RemovalPlan `json:"plan,omitempty"`         // what would be removed, for dry runs
+	DuplicateOf       string       `json:"duplicate_of,omitempty"` // earlier entry in the request for the same user
+	TeamsTotal        int          `json:"teams_total"`
+	TeamsProcessed    int          `json:"teams_processed"`
+	ChannelsTotal     int          `json:"channels_total"`
+	ChannelsProcessed int          `json:"channels_processed"`
+}
+
+// userRef identifies a user to remove and how to look them up.
+type userRef struct {
+	value  string
+	lookup func(p *Plugin, value string) (*model.User, error)
+}
+
+func (p *Plugin) handleRemoveUsersFromAllTeamsAndChannels(w http.ResponseWriter, r *http.Request) {
+	var writeError = func(errorString string, statusCode int) {
+		w.WriteHeader(statusCode)
+		_ = json.NewEncoder(w).Encode(ErrorResponse{errorString})
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(fmt.Sprintf("unexpected HTTP method %s. Should be POST", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	requesterID := r.Header.Get("Mattermost-User-Id")
+	if requesterID == "" {
+		writeError("request is not from an authenticated user", http.StatusUnauthorized)
+		return
+	}
+
+	err := p.ensureSystemAdmin(requesterID)
+	if err != nil {
+		writeError(fmt.Sprintf("error verifying whether user %s is a system admin: %s", requesterID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkRemovalBodySize)
+	refs, err := parseBulkRemovalRequest(r)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(fmt.Sprintf("request body too large, the maximum is %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		writeError(fmt.Sprintf("error parsing request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	job, err := p.enqueueRemovalJob(refs, requesterID, false)
+	if err != nil {
+		err = errors.Wrap(err, "error processing request")
+		p.API.LogError(err.Error())
+		writeError(err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(RemovalJobResponse{job.ID})
+}
+
+// removeUserRef looks up a user and removes them from all teams and channels, capturing any
+// error in the result rather than returning it. seen maps the IDs of users already looked up
+// to the entry that named them, so a user named twice, such as by ID and by email, is only
+// removed once.
+func (p *Plugin) removeUserRef(ctx context.Context, ref userRef, requesterID string, seen map[string]string, progress removalProgress) UserRemovalResult {
+	result := UserRemovalResult{User: ref.value}
+
+	user, err := ref.lookup(p, ref.value)
+	if err == nil {
+		result.UserID = user.Id
+		if first, ok := seen[user.Id]; ok {
+			result.DuplicateOf = first
+			return result
+		}
+		seen[user.Id] = ref.value
+		err = p.removeUser(ctx, user, requesterID, func(counts removalCounts) {
+			result.TeamsTotal = counts.TeamsTotal
+			result.TeamsProcessed = counts.TeamsProcessed
+			result.ChannelsTotal = counts.ChannelsTotal
+			result.ChannelsProcessed = counts.ChannelsProcessed
+			progress(counts)
+		})
+	}
+	if err != nil {
+		p.API.LogError("Error removing user from all teams and channels", "user", ref.value, "err", err.Error())
+		result.Error = err.Error()
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+// planUserRef looks up a user and lists the teams and channels they would be removed from,
+// capturing any error in the result rather than returning it.
+func (p *Plugin) planUserRef(ref userRef) UserRemovalResult {
+	result := UserRemovalResult{User: ref.value}
+
+	user, err := ref.lookup(p, ref.value)
+	if err == nil {
+		result.UserID = user.Id
+		result.Plan, err = p.planUserRemoval(user)
+	}
+	if err != nil {
+		p.API.LogError("Error planning removal of user from all teams and channels", "user", ref.value, "err", err.Error())
+		result.Error = err.Error()
+		return result
+	}
+	result.TeamsTotal = result.Plan.TeamCount
+	result.ChannelsTotal = result.Plan.ChannelCount
+	result.Success = true
+	return result
+}
+
+// parseBulkRemovalRequest reads the users to remove from a JSON body, a CSV body, or a CSV
+// file uploaded as multipart form data.
+func parseBulkRemovalRequest(r *http.Request) ([]userRef, error) {
+	defer r.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var refs []userRef
+	switch mediaType {
+	case "text/csv":
+		values, err := parseUserCSV(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		refs = refsFor(values, lookupUser)
+	case "multipart/form-data":
+		file, _, err := r.FormFile(bulkRemovalFileKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading uploaded file '%s'", bulkRemovalFileKey)
+		}
+		defer file.Close()
+		values, err := parseUserCSV(file)
+		if err != nil {
+			return nil, err
+		}
+		refs = refsFor(values, lookupUser)
+	default:
+		var payload BulkPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			return nil, errors.Wrap(err, "error decoding users payload")
+		}
+		refs = append(refs, refsFor(payload.UserIDs, lookupUserByID)...)
+		refs = append(refs, refsFor(payload.Usernames, lookupUserByUsername)...)
+		refs = append(refs, refsFor(payload.Emails, lookupUserByEmail)...)
+		refs = append(refs, refsFor(payload.Users, lookupUser)...)
+	}
+
+	if len(refs) == 0 {
+		return nil, errors.New("please provide at least one user")
+	}
+	if len(refs) > maxBulkRemovalUsers {
+		return nil, fmt.Errorf("too many users: %d, the maximum is %d", len(refs), maxBulkRemovalUsers)
+	}
+	return refs, nil
+}
+
+func refsFor(values []string, lookup func(p *Plugin, value string) (*model.User, error)) []userRef {
+	refs := make([]userRef, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		refs = append(refs, userRef{value: value, lookup: lookup})
+	}
+	return refs
+}
+
+// parseUserCSV reads user IDs, usernames or emails from the first column of a CSV file. A
+// header row naming the column is skipped.
+func parseUserCSV(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading CSV")
+	}
+
+	values := make([]string, 0, len(records))
+	for i, record := range records {
+		if len(record) == 0 {
+			continue
+		}
+		value := strings.TrimSpace(record[0])
+		if i == 0 && isUserCSVHeader(value) {
+			continue
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func isUserCSVHeader(s string) bool {
+	switch strings.ToLower(s) {
+	case "user", "users", "id", "user_id", "userid", "username", "email":
+		return true
+	}
+	return false
+}
+
+// knownUserRef refers to a user that has already been looked up.
+func knownUserRef(user *model.User) userRef {
+	return userRef{
+		value: user.Username,
+		lookup: func(*Plugin, string) (*model.User, error) {
+			return user, nil
+		},
+	}
+}
+
+func lookupUserByID(p *Plugin, userID string) (*model.User, error) {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get user with id %s", userID)
+	}
+	return user, nil
+}
+
+func lookupUserByUsername(p *Plugin, username string) (*model.User, error) {
+	user, appErr := p.API.GetUserByUsername(strings.TrimPrefix(username, "@"))
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get user with username %s", username)
+	}
+	return user, nil
+}
+
+func lookupUserByEmail(p *Plugin, email string) (*model.User, error) {
+	user, appErr := p.API.GetUserByEmail(email)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get user with email %s", email)
+	}
+	return user, nil
+}
+
+// lookupUser finds a user by email, ID or username, depending on the form of the value.
+func lookupUser(p *Plugin, value string) (*model.User, error) {
+	if strings.Contains(value, "@") && !strings.HasPrefix(value, "@") {
+		return lookupUserByEmail(p, value)
+	}
+	if model.IsValidId(value) {
+		if user, err := lookupUserByID(p, value); err == nil {
+			return user, nil
+		}
+	}
+	return lookupUserByUsername(p, value)
+}
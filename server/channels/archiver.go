@@ -2,10 +2,12 @@ package channels
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v6/model"
 
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
@@ -22,24 +24,45 @@ const (
 type ArchiverOpts struct {
 	StaleChannelOpts store.StaleChannelOpts
 
-	BatchSize   int
-	ListOnly    bool // don't archive channels, just list results
-	MaxWarnings int
+	RunID       string // optional ID for the run; a new ID is generated if empty
+	PolicyName  string // name of the policy for scheduled runs, recorded in the run history
+	TriggeredBy string // ID of the user who started the run, or store.TriggeredBySchedule
 
-	ProgressFn func(results *ArchiverResults) // optional callback to receive results per batch
-	Bot        *bot.Bot                       // optional bot for posting channel archived notification posts
+	BatchSize       int
+	ListOnly        bool // don't archive channels, just list results
+	GracePeriodDays int  // days between warning a channel and archiving it; zero archives without warning
+	NoArchiveNotice bool // don't post a notice in channels when they are archived
+	NotifyOwners    bool // send a direct message to channel creators and admins when warning or archiving
+
+	// DirectChannelAction determines how direct and group message channels are cleaned up; it is
+	// required when StaleChannelOpts includes those channel types.
+	DirectChannelAction DirectChannelAction
+
+	ProgressFn  func(results *ArchiverResults) // optional callback to receive results per batch
+	Bot         *bot.Bot                       // optional bot for posting notification posts; required for warnings
+	Preferences PreferenceUpdater              // used to hide direct and group message channels
 }
 
 type ArchiverResults struct {
+	RunID            string
 	ChannelsArchived []string
+	ChannelsWarned   []string
+	StaleChannels    []*store.StaleChannel // all channels found stale by the run
 	ExitReason       Reason
 	Duration         time.Duration
 	start            time.Time
 }
 
 func ArchiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts) (results *ArchiverResults, retErr error) {
+	runID := opts.RunID
+	if runID == "" {
+		runID = model.NewId()
+	}
+
 	results = &ArchiverResults{
+		RunID:            runID,
 		ChannelsArchived: make([]string, 0),
+		ChannelsWarned:   make([]string, 0),
 		ExitReason:       ReasonDone,
 		start:            time.Now(),
 	}
@@ -52,34 +75,66 @@ func ArchiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client
 			results.ExitReason = ReasonError
 		}
 		results.Duration = time.Since(results.start)
+		recordRun(sqlstore, client, opts, results, retErr)
 	}()
 
+	if opts.Bot != nil {
+		// posts made by the bot, such as warnings, must not reset the channel's inactivity.
+		ignore := make([]string, 0, len(opts.StaleChannelOpts.IgnorePostsByUsers)+1)
+		ignore = append(ignore, opts.StaleChannelOpts.IgnorePostsByUsers...)
+		opts.StaleChannelOpts.IgnorePostsByUsers = append(ignore, opts.Bot.ID())
+	}
+
 	if opts.ListOnly {
 		return results, listStaleChannels(ctx, sqlstore, opts, results)
 	}
+
+	if opts.GracePeriodDays > 0 && opts.Bot == nil {
+		return results, errors.New("a bot is required to post warnings")
+	}
+	if opts.StaleChannelOpts.IncludeChannelTypeDirect || opts.StaleChannelOpts.IncludeChannelTypeGroup {
+		if opts.DirectChannelAction == "" || opts.DirectChannelAction == DirectChannelActionNone {
+			return results, errors.New("a direct channel action is required to clean up direct and group message channels")
+		}
+		if opts.Preferences == nil {
+			return results, errors.New("a preference updater is required to clean up direct and group message channels")
+		}
+	}
 	return results, archiveStaleChannels(ctx, sqlstore, client, opts, results)
 }
 
 func archiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts, results *ArchiverResults) error {
-	for {
-		staleChannels, more, err := sqlstore.GetStaleChannels(opts.StaleChannelOpts, 0, opts.BatchSize)
-		if err != nil {
-			results.ExitReason = ReasonError
-			return fmt.Errorf("cannot fetch stale channels: %w", err)
+	// Fetch the full list up front; channels that are warned or still within their grace period
+	// remain stale, so paging while archiving would revisit them.
+	staleChannels, err := fetchStaleChannels(ctx, sqlstore, opts)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			results.ExitReason = ReasonCancelled
+			return nil
 		}
+		results.ExitReason = ReasonError
+		return err
+	}
+	results.StaleChannels = staleChannels
 
-		for _, ch := range staleChannels {
-			// archive the channel after posting notice.
-			if opts.Bot != nil {
-				msg := fmt.Sprintf("This channel has been archived due to inactivity for more than %d days.", opts.StaleChannelOpts.AgeInDays)
-				_ = opts.Bot.SendPost(ch.Id, msg)
-			}
-			appErr := client.Channel.Delete(ch.Id)
-			if appErr != nil {
-				return fmt.Errorf("cannot archive channel %s (%s): %w", ch.Name, ch.Id, err)
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(staleChannels)
+	}
+
+	now := time.Now()
+
+	for i, ch := range staleChannels {
+		acted, err := processStaleChannel(ctx, sqlstore, client, opts, ch, now, results)
+		if err != nil {
+			if ctx.Err() != nil {
+				results.ExitReason = ReasonCancelled
+				return nil
 			}
-			results.ChannelsArchived = append(results.ChannelsArchived, fmt.Sprintf("%s (%s)", ch.Id, ch.Name))
+			return err
+		}
 
+		if acted {
 			// sleep a short time so we don't peg the cpu
 			select {
 			case <-time.After(time.Millisecond * 10):
@@ -89,12 +144,12 @@ func archiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client
 			}
 		}
 
-		if opts.ProgressFn != nil {
-			opts.ProgressFn(results)
+		if (i+1)%batchSize != 0 || i+1 == len(staleChannels) {
+			continue
 		}
 
-		if !more {
-			return nil
+		if opts.ProgressFn != nil {
+			opts.ProgressFn(results)
 		}
 
 		// sleep so we don't peg the cpu; longer here to allow websocket events to flush
@@ -105,32 +160,132 @@ func archiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client
 			return nil
 		}
 	}
+
+	if opts.ProgressFn != nil {
+		opts.ProgressFn(results)
+	}
+	return nil
+}
+
+// processStaleChannel warns or archives a stale channel, depending on the grace period and any
+// previous warning. Direct and group message channels are cleaned up without warning. Returns
+// true if the channel was warned, archived or cleaned up.
+func processStaleChannel(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts, sc *store.StaleChannel, now time.Time, results *ArchiverResults) (bool, error) {
+	ch := sc.Channel
+	if isDirectChannel(ch) {
+		if err := cleanUpDirectChannel(ctx, sqlstore, client, opts, ch, model.GetMillisForTime(now), results); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	state, err := sqlstore.GetChannelState(ch.Id)
+	if err != nil {
+		return false, fmt.Errorf("cannot fetch state for channel %s (%s): %w", ch.Name, ch.Id, err)
+	}
+
+	nowMillis := model.GetMillisForTime(now)
+	ageInDays := opts.StaleChannelOpts.AgeInDaysForTeam(ch.TeamId)
+
+	// there is no one left to read a warning in channels whose members are all deactivated.
+	warn := opts.GracePeriodDays > 0 && !opts.StaleChannelOpts.AllMembersDeactivated
+
+	if warn {
+		switch getWarningStatus(state, ageInDays, opts.GracePeriodDays, now) {
+		case warningNeeded:
+			msg := fmt.Sprintf("This channel has had no activity for more than %d days and will be archived in %d days unless there is new activity.",
+				ageInDays, opts.GracePeriodDays)
+			if err := opts.Bot.SendPost(ch.Id, msg); err != nil {
+				return false, fmt.Errorf("cannot post warning to channel %s (%s): %w", ch.Name, ch.Id, err)
+			}
+
+			// a new warning starts a new stale period
+			state.FirstStaleAt = nowMillis
+			state.WarningCount++
+			state.LastWarnedAt = nowMillis
+			state.ArchivedAt = 0
+			state.ArchivedByRun = ""
+			if err := sqlstore.SaveChannelState(state); err != nil {
+				return false, fmt.Errorf("cannot save state for channel %s (%s): %w", ch.Name, ch.Id, err)
+			}
+			results.ChannelsWarned = append(results.ChannelsWarned, fmt.Sprintf("%s (%s)", ch.Id, ch.Name))
+			notifyOwners(sqlstore, client, opts, ch, warningOwnerMessage(sc, ageInDays, opts.GracePeriodDays))
+			return true, nil
+		case warningPending:
+			return false, nil
+		}
+	}
+
+	reason := fmt.Sprintf("due to inactivity for more than %d days", ageInDays)
+	if opts.StaleChannelOpts.AllMembersDeactivated {
+		reason = "because all of its members have been deactivated"
+	}
+
+	// archive the channel after posting notice.
+	if opts.Bot != nil && !opts.NoArchiveNotice {
+		_ = opts.Bot.SendPost(ch.Id, fmt.Sprintf("This channel has been archived %s.", reason))
+	}
+	if err := client.Channel.Delete(ch.Id); err != nil {
+		return false, fmt.Errorf("cannot archive channel %s (%s): %w", ch.Name, ch.Id, err)
+	}
+	results.ChannelsArchived = append(results.ChannelsArchived, fmt.Sprintf("%s (%s)", ch.Id, ch.Name))
+	notifyOwners(sqlstore, client, opts, ch, archivedOwnerMessage(sc, reason))
+
+	if !warn {
+		state.FirstStaleAt = nowMillis
+	}
+	state.ArchivedAt = nowMillis
+	state.ArchivedByRun = results.RunID
+	if err := sqlstore.SaveChannelState(state); err != nil {
+		// the channel is already archived; don't abort the run.
+		client.Log.Warn("Channel Archiver cannot save channel state", "channel_id", ch.Id, "err", err)
+	}
+	return true, nil
 }
 
 func listStaleChannels(ctx context.Context, sqlstore *store.SQLStore, opts ArchiverOpts, results *ArchiverResults) error {
+	staleChannels, err := fetchStaleChannels(ctx, sqlstore, opts)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			results.ExitReason = ReasonCancelled
+			return nil
+		}
+		results.ExitReason = ReasonError
+		return err
+	}
+
+	results.StaleChannels = staleChannels
+	now := time.Now()
+	for _, ch := range staleChannels {
+		results.ChannelsArchived = append(results.ChannelsArchived, fmt.Sprintf("**%s** (%s) idle for %d days, last %s activity",
+			ch.Name, ch.Id, ch.IdleDays(now), ch.LastActivitySource))
+	}
+	return nil
+}
+
+// fetchStaleChannels pages through all stale channels matching the options. If the context is
+// canceled the context's error is returned.
+func fetchStaleChannels(ctx context.Context, sqlstore *store.SQLStore, opts ArchiverOpts) ([]*store.StaleChannel, error) {
+	channels := make([]*store.StaleChannel, 0)
 	page := 0
 	for {
 		staleChannels, more, err := sqlstore.GetStaleChannels(opts.StaleChannelOpts, page, opts.BatchSize)
 		if err != nil {
-			results.ExitReason = ReasonError
-			return fmt.Errorf("cannot fetch stale channels: %w", err)
+			return nil, fmt.Errorf("cannot fetch stale channels: %w", err)
 		}
 		page++
 
-		for _, ch := range staleChannels {
-			results.ChannelsArchived = append(results.ChannelsArchived, fmt.Sprintf("**%s** (%s)", ch.Name, ch.Id))
-		}
+		channels = append(channels, staleChannels...)
 
 		if !more {
-			return nil
+			return channels, nil
 		}
 
 		// sleep a short time so we don't peg the cpu
 		select {
 		case <-time.After(time.Millisecond * 10):
 		case <-ctx.Done():
-			results.ExitReason = ReasonCancelled
-			return nil
+			return nil, ctx.Err()
 		}
 	}
 }
@@ -0,0 +1,135 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v6/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+const (
+	purgeBatchSize = 200
+)
+
+// DirectChannelAction determines how stale direct and group message channels are cleaned up,
+// since they cannot be archived.
+type DirectChannelAction string
+
+const (
+	DirectChannelActionNone  DirectChannelAction = "none"  // leave direct and group message channels alone
+	DirectChannelActionHide  DirectChannelAction = "hide"  // hide the channel from its members' sidebars
+	DirectChannelActionPurge DirectChannelAction = "purge" // hide the channel and soft delete all of its posts
+)
+
+// ParseDirectChannelAction parses a direct channel action, where an empty string means none.
+func ParseDirectChannelAction(s string) (DirectChannelAction, error) {
+	switch action := DirectChannelAction(s); action {
+	case "", DirectChannelActionNone:
+		return DirectChannelActionNone, nil
+	case DirectChannelActionHide, DirectChannelActionPurge:
+		return action, nil
+	default:
+		return DirectChannelActionNone, fmt.Errorf("invalid direct channel action '%s', must be one of '%s', '%s' or '%s'",
+			s, DirectChannelActionNone, DirectChannelActionHide, DirectChannelActionPurge)
+	}
+}
+
+// PreferenceUpdater updates user preferences. plugin.API satisfies this interface.
+type PreferenceUpdater interface {
+	UpdatePreferencesForUser(userID string, preferences []model.Preference) *model.AppError
+}
+
+func isDirectChannel(ch *model.Channel) bool {
+	return ch.Type == model.ChannelTypeDirect || ch.Type == model.ChannelTypeGroup
+}
+
+// cleanUpDirectChannel hides a stale direct or group message channel from its members and, when
+// purging, deletes its posts. Members see the channel again as soon as anyone posts in it.
+func cleanUpDirectChannel(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts, ch *model.Channel, nowMillis int64, results *ArchiverResults) error {
+	if opts.Preferences == nil {
+		return fmt.Errorf("cannot hide channel %s: no preference updater", ch.Id)
+	}
+
+	members, err := client.Channel.ListMembers(ch.Id, 0, 100)
+	if err != nil {
+		return fmt.Errorf("cannot fetch members for channel %s: %w", ch.Id, err)
+	}
+
+	for _, member := range members {
+		pref := model.Preference{
+			UserId:   member.UserId,
+			Category: model.PreferenceCategoryGroupChannelShow,
+			Name:     ch.Id,
+			Value:    "false",
+		}
+		if ch.Type == model.ChannelTypeDirect {
+			pref.Category = model.PreferenceCategoryDirectChannelShow
+			pref.Name = ch.GetOtherUserIdForDM(member.UserId)
+			if pref.Name == "" {
+				pref.Name = member.UserId // DM with self
+			}
+		}
+		if appErr := opts.Preferences.UpdatePreferencesForUser(member.UserId, []model.Preference{pref}); appErr != nil {
+			return fmt.Errorf("cannot hide channel %s for user %s: %w", ch.Id, member.UserId, appErr)
+		}
+	}
+
+	if opts.DirectChannelAction == DirectChannelActionPurge {
+		if err := purgePosts(ctx, client, ch.Id); err != nil {
+			return err
+		}
+	}
+	results.ChannelsArchived = append(results.ChannelsArchived, fmt.Sprintf("%s (%s)", ch.Id, ch.Name))
+
+	state, err := sqlstore.GetChannelState(ch.Id)
+	if err == nil {
+		state.FirstStaleAt = nowMillis
+		state.ArchivedAt = nowMillis
+		state.ArchivedByRun = results.RunID
+		err = sqlstore.SaveChannelState(state)
+	}
+	if err != nil {
+		// the channel is already hidden; don't abort the run.
+		client.Log.Warn("Channel Archiver cannot save channel state", "channel_id", ch.Id, "err", err)
+	}
+	return nil
+}
+
+// purgePosts deletes all posts in a channel, stopping early if the context is canceled. Posts
+// are soft deleted like any other deleted post, so they remain in the database until removed by
+// the server's data retention job.
+func purgePosts(ctx context.Context, client *pluginapi.Client, channelID string) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("purge of channel %s stopped: %w", channelID, err)
+		}
+
+		list, err := client.Post.GetPostsForChannel(channelID, 0, purgeBatchSize)
+		if err != nil {
+			return fmt.Errorf("cannot fetch posts for channel %s: %w", channelID, err)
+		}
+		if len(list.Order) == 0 {
+			return nil
+		}
+
+		for _, postID := range list.Order {
+			post := list.Posts[postID]
+			if post == nil {
+				continue
+			}
+			// replies are deleted along with their root post.
+			if _, ok := list.Posts[post.RootId]; ok && post.RootId != "" {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("purge of channel %s stopped: %w", channelID, err)
+			}
+			if err := client.Post.DeletePost(postID); err != nil {
+				return fmt.Errorf("cannot delete post %s in channel %s: %w", postID, channelID, err)
+			}
+		}
+	}
+}
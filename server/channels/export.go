@@ -0,0 +1,120 @@
+package channels
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+// ExportFormat is the file format for exported stale channel lists.
+type ExportFormat string
+
+const (
+	ExportFormatNone ExportFormat = "none"
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatJSON ExportFormat = "json"
+)
+
+// ParseExportFormat parses an export format, where an empty string means none.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch format := ExportFormat(s); format {
+	case "", ExportFormatNone:
+		return ExportFormatNone, nil
+	case ExportFormatCSV, ExportFormatJSON:
+		return format, nil
+	default:
+		return ExportFormatNone, fmt.Errorf("invalid export format '%s', must be one of '%s', '%s' or '%s'",
+			s, ExportFormatNone, ExportFormatCSV, ExportFormatJSON)
+	}
+}
+
+// ExportedChannel is a row of an exported stale channel list.
+type ExportedChannel struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	DisplayName        string `json:"display_name"`
+	Team               string `json:"team"`
+	Type               string `json:"type"`
+	MemberCount        int64  `json:"member_count"`
+	LastPostAt         string `json:"last_post_at"`     // RFC 3339, empty if the channel has no posts
+	LastActivityAt     string `json:"last_activity_at"` // RFC 3339
+	LastActivitySource string `json:"last_activity_source"`
+	IdleDays           int    `json:"idle_days"`
+	CreatorID          string `json:"creator_id"`
+	Creator            string `json:"creator"`
+}
+
+var exportHeader = []string{"id", "name", "display_name", "team", "type", "member_count", "last_post_at", "last_activity_at", "last_activity_source", "idle_days", "creator_id", "creator"}
+
+// ExportFileName returns the file name for an export in the given format.
+func ExportFileName(format ExportFormat, now time.Time) string {
+	return fmt.Sprintf("stale-channels-%s.%s", now.UTC().Format("2006-01-02-150405"), format)
+}
+
+// ExportStaleChannels writes the channels in the given format.
+func ExportStaleChannels(channels []*store.StaleChannel, format ExportFormat) ([]byte, error) {
+	return exportStaleChannels(channels, format, time.Now())
+}
+
+func exportStaleChannels(channels []*store.StaleChannel, format ExportFormat, now time.Time) ([]byte, error) {
+	rows := make([]ExportedChannel, 0, len(channels))
+	for _, ch := range channels {
+		row := ExportedChannel{
+			ID:                 ch.Id,
+			Name:               ch.Name,
+			DisplayName:        ch.DisplayName,
+			Team:               ch.TeamName,
+			Type:               channelTypeName(ch.Type),
+			MemberCount:        ch.MemberCount,
+			LastActivityAt:     model.GetTimeForMillis(ch.LastActivityAt).UTC().Format(time.RFC3339),
+			LastActivitySource: string(ch.LastActivitySource),
+			IdleDays:           ch.IdleDays(now),
+			CreatorID:          ch.CreatorId,
+			Creator:            ch.CreatorName,
+		}
+		if ch.LastPostAt > 0 {
+			row.LastPostAt = model.GetTimeForMillis(ch.LastPostAt).UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, row)
+	}
+
+	switch format {
+	case ExportFormatJSON:
+		return json.MarshalIndent(rows, "", "  ")
+	case ExportFormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write(exportHeader)
+		for _, row := range rows {
+			_ = w.Write([]string{row.ID, row.Name, row.DisplayName, row.Team, row.Type,
+				strconv.FormatInt(row.MemberCount, 10), row.LastPostAt, row.LastActivityAt, row.LastActivitySource,
+				strconv.Itoa(row.IdleDays), row.CreatorID, row.Creator})
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	default:
+		return nil, fmt.Errorf("cannot export in format '%s'", format)
+	}
+}
+
+func channelTypeName(channelType model.ChannelType) string {
+	switch channelType {
+	case model.ChannelTypeOpen:
+		return "public"
+	case model.ChannelTypePrivate:
+		return "private"
+	case model.ChannelTypeDirect:
+		return "direct"
+	case model.ChannelTypeGroup:
+		return "group"
+	default:
+		return string(channelType)
+	}
+}
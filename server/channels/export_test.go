@@ -0,0 +1,92 @@
+package channels
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+func TestParseExportFormat(t *testing.T) {
+	for _, s := range []string{"", "none"} {
+		format, err := ParseExportFormat(s)
+		require.NoError(t, err)
+		assert.Equal(t, ExportFormatNone, format)
+	}
+
+	format, err := ParseExportFormat("csv")
+	require.NoError(t, err)
+	assert.Equal(t, ExportFormatCSV, format)
+
+	_, err = ParseExportFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestExportStaleChannels(t *testing.T) {
+	lastPost := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	lastActivity := time.Date(2022, 3, 14, 5, 6, 7, 0, time.UTC)
+	now := lastActivity.AddDate(0, 0, 412)
+	staleChannels := []*store.StaleChannel{
+		{
+			Channel: &model.Channel{
+				Id:          "channel1",
+				Name:        "town-square",
+				DisplayName: "Town Square, the \"main\" one",
+				Type:        model.ChannelTypeOpen,
+				CreatorId:   "user1",
+				LastPostAt:  model.GetMillisForTime(lastPost),
+			},
+			TeamName:           "team1",
+			CreatorName:        "alice",
+			MemberCount:        12,
+			LastActivityAt:     model.GetMillisForTime(lastActivity),
+			LastActivitySource: store.ActivitySourceReaction,
+		},
+		{
+			Channel: &model.Channel{
+				Id:          "channel2",
+				Name:        "secret",
+				DisplayName: "Secret",
+				Type:        model.ChannelTypePrivate,
+			},
+			LastActivityAt:     model.GetMillisForTime(lastPost),
+			LastActivitySource: store.ActivitySourceChannel,
+		},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		data, err := exportStaleChannels(staleChannels, ExportFormatCSV, now)
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, "id,name,display_name,team,type,member_count,last_post_at,last_activity_at,last_activity_source,idle_days,creator_id,creator", lines[0])
+		assert.Equal(t, `channel1,town-square,"Town Square, the ""main"" one",team1,public,12,2022-03-04T05:06:07Z,2022-03-14T05:06:07Z,reaction,412,user1,alice`, lines[1])
+		assert.Equal(t, "channel2,secret,Secret,,private,0,,2022-03-04T05:06:07Z,channel,422,,", lines[2])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		data, err := exportStaleChannels(staleChannels, ExportFormatJSON, now)
+		require.NoError(t, err)
+
+		var rows []ExportedChannel
+		require.NoError(t, json.Unmarshal(data, &rows))
+		require.Len(t, rows, 2)
+		assert.Equal(t, "team1", rows[0].Team)
+		assert.Equal(t, "2022-03-04T05:06:07Z", rows[0].LastPostAt)
+		assert.Equal(t, 412, rows[0].IdleDays)
+		assert.Equal(t, "private", rows[1].Type)
+		assert.Empty(t, rows[1].LastPostAt)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		_, err := ExportStaleChannels(staleChannels, ExportFormatNone)
+		assert.Error(t, err)
+	})
+}
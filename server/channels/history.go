@@ -0,0 +1,86 @@
+package channels
+
+import (
+	"encoding/json"
+	"time"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v6/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+// RunHistoryRetention is how long runs are kept in the run history.
+const RunHistoryRetention = 90 * 24 * time.Hour
+
+// runSettings is the snapshot of a run's options recorded in its history.
+type runSettings struct {
+	store.StaleChannelOpts
+	BatchSize           int
+	ListOnly            bool
+	GracePeriodDays     int
+	NoArchiveNotice     bool
+	NotifyOwners        bool
+	DirectChannelAction DirectChannelAction
+}
+
+// RecordFailedRun adds a run that could not start to the run history. opts holds the
+// settings the run would have used.
+func RecordFailedRun(sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts, runErr error) {
+	results := &ArchiverResults{
+		RunID:      model.NewId(),
+		ExitReason: ReasonError,
+		start:      time.Now(),
+	}
+	recordRun(sqlstore, client, opts, results, runErr)
+}
+
+// recordRun saves the run to the run history and removes runs older than RunHistoryRetention.
+// Failures are logged rather than returned since the run itself is complete.
+func recordRun(sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts, results *ArchiverResults, runErr error) {
+	run := newRunHistory(opts, results, runErr)
+	if err := sqlstore.SaveRunHistory(run); err != nil {
+		client.Log.Warn("Channel Archiver cannot save run history", "run_id", results.RunID, "err", err)
+	}
+
+	cutoff := model.GetMillisForTime(results.start.Add(-RunHistoryRetention))
+	if _, err := sqlstore.DeleteRunHistoryBefore(cutoff); err != nil {
+		client.Log.Warn("Channel Archiver cannot prune run history", "err", err)
+	}
+}
+
+func newRunHistory(opts ArchiverOpts, results *ArchiverResults, runErr error) *store.RunHistory {
+	run := &store.RunHistory{
+		RunID:         results.RunID,
+		Policy:        opts.PolicyName,
+		TriggeredBy:   opts.TriggeredBy,
+		ListOnly:      opts.ListOnly,
+		StartAt:       model.GetMillisForTime(results.start),
+		EndAt:         model.GetMillisForTime(results.start.Add(results.Duration)),
+		StaleCount:    len(results.StaleChannels),
+		ArchivedCount: len(results.ChannelsArchived),
+		WarnedCount:   len(results.ChannelsWarned),
+		ExitReason:    string(results.ExitReason),
+	}
+	if opts.ListOnly {
+		// list runs report the stale channels as archived.
+		run.ArchivedCount = 0
+	}
+	if runErr != nil {
+		run.ErrorMessage = runErr.Error()
+	}
+
+	settings, err := json.Marshal(runSettings{
+		StaleChannelOpts:    opts.StaleChannelOpts,
+		BatchSize:           opts.BatchSize,
+		ListOnly:            opts.ListOnly,
+		GracePeriodDays:     opts.GracePeriodDays,
+		NoArchiveNotice:     opts.NoArchiveNotice,
+		NotifyOwners:        opts.NotifyOwners,
+		DirectChannelAction: opts.DirectChannelAction,
+	})
+	if err == nil {
+		run.Settings = string(settings)
+	}
+	return run
+}
@@ -0,0 +1,52 @@
+package channels
+
+import (
+	"fmt"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v6/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+const (
+	notifyDateLayout = "January 2, 2006"
+)
+
+// lastActivityDate returns the date of the channel's last activity as found by the stale channel
+// scan, which ignores posts by the bot such as its own warnings.
+func lastActivityDate(sc *store.StaleChannel) string {
+	return model.GetTimeForMillis(sc.LastActivityAt).Format(notifyDateLayout)
+}
+
+func warningOwnerMessage(sc *store.StaleChannel, ageInDays int, gracePeriodDays int) string {
+	return fmt.Sprintf("The channel **%s** (~%s) has had no activity for more than %d days, since %s, and will be archived in %d days.\n\n"+
+		"To keep it, post in the channel, or run `/channel-archiver keep` in it to exempt it from auto-archiving.",
+		sc.DisplayName, sc.Name, ageInDays, lastActivityDate(sc), gracePeriodDays)
+}
+
+func archivedOwnerMessage(sc *store.StaleChannel, reason string) string {
+	return fmt.Sprintf("The channel **%s** (~%s) has been archived %s. It was last active on %s.\n\n"+
+		"To restore it, ask a System Admin to run `/channel-archiver restore --channel %s`.",
+		sc.DisplayName, sc.Name, reason, lastActivityDate(sc), sc.Id)
+}
+
+// notifyOwners sends a direct message from the bot to the channel's creator and channel admins.
+// Failures are logged rather than returned so they don't abort the run.
+func notifyOwners(sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts, ch *model.Channel, msg string) {
+	if !opts.NotifyOwners || opts.Bot == nil {
+		return
+	}
+
+	ownerIDs, err := sqlstore.GetChannelOwnerIDs(ch.Id, ch.CreatorId)
+	if err != nil {
+		client.Log.Warn("Channel Archiver cannot fetch channel owners", "channel_id", ch.Id, "err", err)
+		return
+	}
+
+	for _, userID := range ownerIDs {
+		if err := opts.Bot.SendDirectPost(userID, msg); err != nil {
+			client.Log.Warn("Channel Archiver cannot notify channel owner", "channel_id", ch.Id, "user_id", userID, "err", err)
+		}
+	}
+}
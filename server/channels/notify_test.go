@@ -0,0 +1,39 @@
+package channels
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+func TestOwnerMessagesUseLastActivity(t *testing.T) {
+	lastActivity := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	warnedAt := time.Date(2023, time.May, 20, 12, 0, 0, 0, time.UTC)
+
+	// the channel's last post is the bot's warning, which the stale scan ignored.
+	sc := &store.StaleChannel{
+		Channel: &model.Channel{
+			Id:          model.NewId(),
+			Name:        "town-hall",
+			DisplayName: "Town Hall",
+			CreateAt:    model.GetMillisForTime(lastActivity.AddDate(-1, 0, 0)),
+			LastPostAt:  model.GetMillisForTime(warnedAt),
+		},
+		LastActivityAt:     model.GetMillisForTime(lastActivity),
+		LastActivitySource: store.ActivitySourcePost,
+	}
+
+	for name, msg := range map[string]string{
+		"warning":  warningOwnerMessage(sc, 60, 14),
+		"archived": archivedOwnerMessage(sc, "due to inactivity for more than 60 days"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Contains(t, msg, "March 1, 2023")
+			assert.NotContains(t, msg, "May 20, 2023")
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+type RestoreResults struct {
+	ChannelsRestored []string
+	Errors           []string
+	ExitReason       Reason
+}
+
+// RestoreChannels unarchives channels previously archived by the Channel Archiver and posts a
+// notice in each restored channel. Errors for individual channels are collected in the results
+// so that one bad channel does not prevent the rest from being restored.
+func RestoreChannels(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, bot *bot.Bot, channelIDs []string) *RestoreResults {
+	results := &RestoreResults{
+		ChannelsRestored: make([]string, 0),
+		Errors:           make([]string, 0),
+		ExitReason:       ReasonDone,
+	}
+
+	for _, channelID := range channelIDs {
+		name, err := restoreChannel(sqlstore, client, bot, channelID)
+		if err != nil {
+			results.Errors = append(results.Errors, err.Error())
+		} else {
+			results.ChannelsRestored = append(results.ChannelsRestored, fmt.Sprintf("%s (%s)", channelID, name))
+		}
+
+		// sleep a short time so we don't peg the cpu
+		select {
+		case <-time.After(time.Millisecond * 10):
+		case <-ctx.Done():
+			results.ExitReason = ReasonCancelled
+			return results
+		}
+	}
+	return results
+}
+
+func restoreChannel(sqlstore *store.SQLStore, client *pluginapi.Client, bot *bot.Bot, channelID string) (string, error) {
+	state, err := sqlstore.GetChannelState(channelID)
+	if err != nil {
+		return "", fmt.Errorf("cannot fetch state for channel %s: %w", channelID, err)
+	}
+	if state.ArchivedAt == 0 {
+		return "", fmt.Errorf("channel %s was not archived by the Channel Archiver", channelID)
+	}
+
+	ch, err := client.Channel.Get(channelID)
+	if err != nil {
+		return "", fmt.Errorf("cannot fetch channel %s: %w", channelID, err)
+	}
+
+	if ch.DeleteAt != 0 {
+		ch.DeleteAt = 0
+		if err := client.Channel.Update(ch); err != nil {
+			return "", fmt.Errorf("cannot restore channel %s (%s): %w", ch.Name, ch.Id, err)
+		}
+	}
+
+	state.ArchivedAt = 0
+	state.ArchivedByRun = ""
+	state.FirstStaleAt = 0
+	state.LastWarnedAt = 0
+	if err := sqlstore.SaveChannelState(state); err != nil {
+		return "", fmt.Errorf("cannot save state for channel %s (%s): %w", ch.Name, ch.Id, err)
+	}
+
+	if bot != nil {
+		_ = bot.SendPost(ch.Id, "This channel has been restored from the archive.")
+	}
+	return ch.Name, nil
+}
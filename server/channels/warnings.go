@@ -0,0 +1,36 @@
+package channels
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+type warningStatus int
+
+const (
+	warningNeeded  warningStatus = iota // channel has no current warning
+	warningPending                      // channel was warned and is within its grace period
+	warningElapsed                      // channel was warned and the grace period has elapsed
+)
+
+// getWarningStatus determines where a stale channel is in the warn-then-archive cycle.
+func getWarningStatus(state *store.ChannelState, ageInDays int, gracePeriodDays int, now time.Time) warningStatus {
+	if state.LastWarnedAt == 0 {
+		return warningNeeded
+	}
+	warnedAt := model.GetTimeForMillis(state.LastWarnedAt)
+
+	// A channel that is stale today has had no activity for ageInDays, so any warning posted before
+	// that was followed by activity and no longer counts.
+	if warnedAt.Before(now.AddDate(0, 0, -ageInDays)) {
+		return warningNeeded
+	}
+
+	if now.Before(warnedAt.AddDate(0, 0, gracePeriodDays)) {
+		return warningPending
+	}
+	return warningElapsed
+}
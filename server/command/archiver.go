@@ -3,7 +3,9 @@ package command
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -14,14 +16,31 @@ import (
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/jobs"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
 )
 
 const (
-	ArchiverTrigger    = "channel-archiver"
-	paramNameDays      = "days"
-	paramNameBatchSize = "batch-size"
-	paramNameExclude   = "exclude"
+	ArchiverTrigger       = "channel-archiver"
+	paramNameDays         = "days"
+	paramNameBatchSize    = "batch-size"
+	paramNameInclude      = "include"
+	paramNameExclude      = "exclude"
+	paramNameMinMembers   = "min-members"
+	paramNameDeactivated  = "deactivated"
+	paramNameDirectAction = "direct-action"
+	paramNameTypes        = "types"
+	paramNameExport       = "export"
+	paramNameMaxMembers   = "max-members"
+	paramNameChannel      = "channel"
+	paramNameRun          = "run"
+	paramNameFrom         = "from"
+	paramNameTo           = "to"
+	paramNameUntil        = "until"
+	paramNameRemove       = "remove"
+	paramNamePage         = "page"
+
+	dateLayout = "2006-01-02"
 )
 
 type ErrInvalidSubCommand struct {
@@ -33,10 +52,14 @@ func (e ErrInvalidSubCommand) Error() string {
 }
 
 type ChannelArchiverCmd struct {
-	client   *pluginapi.Client
-	sqlStore *store.SQLStore
-	commands []*model.AutocompleteData
-	bot      *bot.Bot
+	client      *pluginapi.Client
+	sqlStore    *store.SQLStore
+	commands    []*model.AutocompleteData
+	bot         *bot.Bot
+	preferences channels.PreferenceUpdater
+	runs        *runRegistry
+	jobManager  *jobs.JobManager
+	getConfig   func() *config.Configuration
 }
 
 func getDefaultBatchSize(list bool) int {
@@ -47,18 +70,52 @@ func getDefaultBatchSize(list bool) int {
 }
 
 // RegisterChannelArchiver is called by the plugin to register all necessary commands
-func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore) (*ChannelArchiverCmd, error) {
+func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore, bot *bot.Bot, preferences channels.PreferenceUpdater, jobManager *jobs.JobManager,
+	getConfig func() *config.Configuration) (*ChannelArchiverCmd, error) {
 	cmdArchive := model.NewAutocompleteData("archive", "", "Archive stale channels")
 	cmdList := model.NewAutocompleteData("list", "", "List stale channels that would be archived")
+	cmdRestore := model.NewAutocompleteData("restore", "", "Restore channels archived by the Channel Archiver")
+	cmdKeep := model.NewAutocompleteData("keep", "", "Exempt the current channel from auto-archiving")
+	cmdHistory := model.NewAutocompleteData("history", "", "Show past archive and list runs")
+	cmdRun := model.NewAutocompleteData("run", "[policy]", "Run a scheduled archiver job now with its configured settings")
+	cmdStatus := model.NewAutocompleteData("status", "", "Show the scheduled archiver jobs and any runs in progress")
+	cmdCancel := model.NewAutocompleteData("cancel", "[run ID]", "Cancel an archive or list run in progress")
 	cmdHelp := model.NewAutocompleteData("help", "", "Display help text")
-	commands := []*model.AutocompleteData{cmdArchive, cmdList, cmdHelp}
+	commands := []*model.AutocompleteData{cmdArchive, cmdList, cmdRestore, cmdKeep, cmdRun, cmdStatus, cmdCancel, cmdHistory, cmdHelp}
 
 	cmdArchive.AddNamedTextArgument(paramNameDays, "Number of days of inactivity for a channel to be considered stale", fmt.Sprintf("[int - min %d days]", config.MinAgeInDays), "[0-9]*", true)
 	cmdArchive.AddNamedTextArgument(paramNameBatchSize, fmt.Sprintf("Channels will be archived in batches of this size. (default=%d)", config.DefaultArchiveBatchSize), "[int]", "[0-9]*", false)
-	cmdArchive.AddNamedTextArgument(paramNameExclude, "Comma separated list of channel names/IDs to exclude. No Spaces.", "", "", false)
+	cmdArchive.AddNamedTextArgument(paramNameInclude, "Comma separated list of channel names/IDs or patterns (e.g. tmp-*). Only matching channels are archived. No Spaces.", "", "", false)
+	cmdArchive.AddNamedTextArgument(paramNameExclude, "Comma separated list of channel names/IDs or patterns (e.g. incident-*, re:^legal-) to exclude. No Spaces.", "", "", false)
+	cmdArchive.AddNamedTextArgument(paramNameDeactivated, "Archive channels whose members are all deactivated users or bots, regardless of activity. Days is not needed.", "", "", false)
+	cmdArchive.AddNamedTextArgument(paramNameTypes, "Comma separated list of channel types to archive. Direct and group require direct-action. (default=public,private)", "[public,private,direct,group]", "", false)
+	cmdArchive.AddNamedTextArgument(paramNameDirectAction, "Also clean up stale direct and group message channels by hiding them, or hiding them and deleting their posts (deleted posts are kept until data retention removes them)", "[hide|purge]", "", false)
+	cmdArchive.AddNamedTextArgument(paramNameMinMembers, "Only archive channels with at least this many members", "[int]", "[0-9]*", false)
+	cmdArchive.AddNamedTextArgument(paramNameMaxMembers, "Only archive channels with at most this many members", "[int]", "[0-9]*", false)
 
 	cmdList.AddNamedTextArgument(paramNameDays, "Number of days of inactivity for a channel to be considered stale", fmt.Sprintf("[int - min %d days]", config.MinAgeInDays), "[0-9]*", true)
-	cmdList.AddNamedTextArgument(paramNameExclude, "Comma separated list of channel names/IDs to exclude. No Spaces.", "", "", false)
+	cmdList.AddNamedTextArgument(paramNameInclude, "Comma separated list of channel names/IDs or patterns (e.g. tmp-*). Only matching channels are listed. No Spaces.", "", "", false)
+	cmdList.AddNamedTextArgument(paramNameExclude, "Comma separated list of channel names/IDs or patterns (e.g. incident-*, re:^legal-) to exclude. No Spaces.", "", "", false)
+	cmdList.AddNamedTextArgument(paramNameDeactivated, "List channels whose members are all deactivated users or bots, regardless of activity. Days is not needed.", "", "", false)
+	cmdList.AddNamedTextArgument(paramNameExport, "Send the list as a file by direct message instead of posting it", "[csv|json]", "", false)
+	cmdList.AddNamedTextArgument(paramNameTypes, "Comma separated list of channel types to list. Direct and group require direct-action. (default=public,private)", "[public,private,direct,group]", "", false)
+	cmdList.AddNamedTextArgument(paramNameDirectAction, "Also list stale direct and group message channels", "[hide|purge]", "", false)
+	cmdList.AddNamedTextArgument(paramNameMinMembers, "Only list channels with at least this many members", "[int]", "[0-9]*", false)
+	cmdList.AddNamedTextArgument(paramNameMaxMembers, "Only list channels with at most this many members", "[int]", "[0-9]*", false)
+
+	cmdRestore.AddNamedTextArgument(paramNameChannel, "Name or ID of a single channel to restore", "[channel]", "", false)
+	cmdRestore.AddNamedTextArgument(paramNameRun, "ID of the archiver run whose channels should be restored", "[run ID]", "", false)
+	cmdRestore.AddNamedTextArgument(paramNameFrom, "Restore channels archived on or after this date", "[YYYY-MM-DD]", "", false)
+	cmdRestore.AddNamedTextArgument(paramNameTo, "Restore channels archived on or before this date", "[YYYY-MM-DD]", "", false)
+
+	cmdKeep.AddNamedTextArgument(paramNameUntil, "Keep the channel until this date. Omit to keep it indefinitely.", "[YYYY-MM-DD]", "", false)
+	cmdKeep.AddNamedTextArgument(paramNameRemove, "Remove the exemption so the channel can be auto-archived again", "", "", false)
+
+	cmdHistory.AddNamedTextArgument(paramNameRun, "ID of a run to show in detail", "[run ID]", "", false)
+	cmdHistory.AddNamedTextArgument(paramNamePage, "Page of runs to show, starting at 0", "[int]", "[0-9]*", false)
+
+	cmdRun.AddTextArgument("Name of the policy to run. Omit for the default policy.", "[policy]", "")
+	cmdCancel.AddTextArgument("ID of the run to cancel, as shown by status", "[run ID]", "")
 
 	names := []string{}
 	for _, c := range commands {
@@ -74,11 +131,6 @@ func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore) (*
 		return nil, errors.Wrap(err, "failed to get icon data")
 	}
 
-	bot, err := bot.New(client)
-	if err != nil {
-		return nil, err
-	}
-
 	err = client.SlashCommand.Register(&model.Command{
 		Trigger:              ArchiverTrigger,
 		DisplayName:          "Channel Archiver",
@@ -94,13 +146,22 @@ func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore) (*
 	}
 
 	return &ChannelArchiverCmd{
-		client:   client,
-		sqlStore: store,
-		commands: commands,
-		bot:      bot,
+		client:      client,
+		sqlStore:    store,
+		preferences: preferences,
+		commands:    commands,
+		bot:         bot,
+		runs:        newRunRegistry(),
+		jobManager:  jobManager,
+		getConfig:   getConfig,
 	}, nil
 }
 
+// Close cancels any archive or list runs in progress and waits for them to exit.
+func (ca *ChannelArchiverCmd) Close(timeout time.Duration) error {
+	return ca.runs.stopAll(timeout)
+}
+
 func (ca *ChannelArchiverCmd) Execute(args *model.CommandArgs) (*model.CommandResponse, error) {
 	params := parseNamedArgs(args.Command)
 	subCommand := params[SubCommandKey]
@@ -113,6 +174,18 @@ func (ca *ChannelArchiverCmd) Execute(args *model.CommandArgs) (*model.CommandRe
 		msg, err = ca.handleArchive(args, params, false)
 	case "list":
 		msg, err = ca.handleArchive(args, params, true)
+	case "restore":
+		msg, err = ca.handleRestore(args, params)
+	case "keep":
+		msg, err = ca.handleKeep(args, params)
+	case "run":
+		msg, err = ca.handleRun(args)
+	case "status":
+		msg, err = ca.handleStatus(args)
+	case "history":
+		msg, err = ca.handleHistory(args, params)
+	case "cancel":
+		msg, err = ca.handleCancel(args)
 	case "help":
 		msg, err = ca.handleHelp()
 	default:
@@ -131,9 +204,15 @@ func (ca *ChannelArchiverCmd) handleArchive(args *model.CommandArgs, params map[
 		return fmt.Sprintf("You require %s permissions to execute this command.", model.PermissionManageSystem.Id), nil
 	}
 
-	days, err := config.ParseInt(params[paramNameDays], config.MinAgeInDays, config.MaxAgeInDays)
-	if err != nil {
-		return fmt.Sprintf("Missing or invalid '%s' parameter: %s", paramNameDays, err.Error()), nil
+	_, deactivated := params[paramNameDeactivated]
+
+	var days int
+	var err error
+	if _, ok := params[paramNameDays]; ok || !deactivated {
+		days, err = config.ParseInt(params[paramNameDays], config.MinAgeInDays, config.MaxAgeInDays)
+		if err != nil {
+			return fmt.Sprintf("Missing or invalid '%s' parameter: %s", paramNameDays, err.Error()), nil
+		}
 	}
 
 	batchSize := getDefaultBatchSize(list)
@@ -144,43 +223,341 @@ func (ca *ChannelArchiverCmd) handleArchive(args *model.CommandArgs, params map[
 		}
 	}
 
+	var include []string
+	if in, ok := params[paramNameInclude]; ok {
+		include = strings.Split(in, ",")
+		if err := store.ValidateChannelPatterns(include); err != nil {
+			return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameInclude, err.Error()), nil
+		}
+	}
+
 	var exclude []string
 	if ex, ok := params[paramNameExclude]; ok {
 		exclude = strings.Split(ex, ",")
+		if err := store.ValidateChannelPatterns(exclude); err != nil {
+			return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameExclude, err.Error()), nil
+		}
 	}
 
+	var minMembers, maxMembers int
+	if v, ok := params[paramNameMinMembers]; ok {
+		if minMembers, err = config.ParseInt(v, 0, math.MaxInt32); err != nil {
+			return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameMinMembers, err.Error()), nil
+		}
+	}
+	if v, ok := params[paramNameMaxMembers]; ok {
+		if maxMembers, err = config.ParseInt(v, 0, math.MaxInt32); err != nil {
+			return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameMaxMembers, err.Error()), nil
+		}
+	}
+	if err := config.ValidateMemberLimits(minMembers, maxMembers); err != nil {
+		return fmt.Sprintf("Invalid '%s' or '%s' parameter: %s", paramNameMinMembers, paramNameMaxMembers, err.Error()), nil
+	}
+
+	exportFormat, err := channels.ParseExportFormat(params[paramNameExport])
+	if err != nil {
+		return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameExport, err.Error()), nil
+	}
+
+	directAction, err := channels.ParseDirectChannelAction(params[paramNameDirectAction])
+	if err != nil {
+		return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameDirectAction, err.Error()), nil
+	}
+
+	// Manual runs notify like the default policy, and like every job ignore the bot's own posts.
+	cfg := ca.getConfig()
 	opts := channels.ArchiverOpts{
 		StaleChannelOpts: store.StaleChannelOpts{
-			AgeInDays:                 days,
-			ExcludeChannels:           exclude,
-			IncludeChannelTypeOpen:    true,
-			IncludeChannelTypePrivate: true,
-		},
-		BatchSize: batchSize,
-		ListOnly:  list,
-		ProgressFn: func(results *channels.ArchiverResults) {
-			if list {
-				return
-			}
-			ca.client.Log.Debug("Channel Archiver", "archived_count", len(results.ChannelsArchived))
-			msg := fmt.Sprintf("Channel-archiver progress -- %d channels archived.", len(results.ChannelsArchived))
-			_ = ca.bot.SendEphemeralPost(args.ChannelId, args.UserId, msg)
+			AgeInDays:             days,
+			AllMembersDeactivated: deactivated,
+			MinMembers:            minMembers,
+			MaxMembers:            maxMembers,
+			IncludeChannels:       include,
+			ExcludeChannels:       exclude,
 		},
+		BatchSize:           batchSize,
+		ListOnly:            list,
+		NoArchiveNotice:     !cfg.PostArchiveNotice,
+		NotifyOwners:        cfg.NotifyOwners,
+		DirectChannelAction: directAction,
+		Preferences:         ca.preferences,
+		Bot:                 ca.bot,
 	}
 
-	results, err := channels.ArchiveStaleChannels(context.TODO(), ca.sqlStore, ca.client, opts)
+	types, ok := params[paramNameTypes]
+	if !ok {
+		types = "public,private"
+		if directAction != channels.DirectChannelActionNone {
+			types += ",direct,group"
+		}
+	}
+	if err := parseChannelTypes(types, &opts.StaleChannelOpts); err != nil {
+		return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameTypes, err.Error()), nil
+	}
+	if (opts.StaleChannelOpts.IncludeChannelTypeDirect || opts.StaleChannelOpts.IncludeChannelTypeGroup) && directAction == channels.DirectChannelActionNone {
+		return fmt.Sprintf("The '%s' parameter is required for direct and group channel types.", paramNameDirectAction), nil
+	}
+
+	action := runActionArchive
+	if list {
+		action = runActionList
+	}
+	run := newManualRun(model.NewId(), args.UserId, action)
+	opts.RunID = run.id
+	opts.TriggeredBy = args.UserId
+	opts.ProgressFn = func(results *channels.ArchiverResults) {
+		run.setArchived(len(results.ChannelsArchived))
+		if list {
+			return
+		}
+		ca.client.Log.Debug("Channel Archiver", "run_id", run.id, "archived_count", len(results.ChannelsArchived))
+		msg := fmt.Sprintf("Channel-archiver progress -- %d channels archived.", len(results.ChannelsArchived))
+		_ = ca.bot.SendEphemeralPost(args.ChannelId, args.UserId, msg)
+	}
+
+	ca.startRun(args, run, func(ctx context.Context) string {
+		return ca.runArchive(ctx, args, opts, exportFormat)
+	})
+
+	verb := "Archiving"
+	if list {
+		verb = "Listing"
+	}
+	return fmt.Sprintf("%s stale channels in the background (run ID %s).\nUse `/%s status` to see runs in progress, or `/%s cancel %s` to stop this run.",
+		verb, run.id, ArchiverTrigger, ArchiverTrigger, run.id), nil
+}
+
+// startRun registers the run and calls f in the background, sending the message it returns to
+// the user when done.
+func (ca *ChannelArchiverCmd) startRun(args *model.CommandArgs, run *manualRun, f func(ctx context.Context) string) {
+	ca.runs.add(run)
+	go func() {
+		defer func() {
+			ca.runs.remove(run.id)
+			close(run.exitSignal)
+		}()
+		msg := f(run.ctx)
+		_ = ca.bot.SendEphemeralPost(args.ChannelId, args.UserId, msg)
+	}()
+}
+
+// runArchive runs the archiver and returns a message with the results for the user.
+func (ca *ChannelArchiverCmd) runArchive(ctx context.Context, args *model.CommandArgs, opts channels.ArchiverOpts, exportFormat channels.ExportFormat) string {
+	list := opts.ListOnly
+
+	results, err := channels.ArchiveStaleChannels(ctx, ca.sqlStore, ca.client, opts)
 	if err != nil {
-		return fmt.Sprintf("Error archiving channels: %s", err.Error()), nil
+		return fmt.Sprintf("Error archiving channels (run ID %s): %s", opts.RunID, err.Error())
+	}
+
+	if list && exportFormat != channels.ExportFormatNone {
+		if err := ca.exportChannelList(args, results.StaleChannels, exportFormat); err != nil {
+			return fmt.Sprintf("Error exporting channel list: %s", err.Error())
+		}
+		return fmt.Sprintf("count: %d\n%s\nThe list has been sent to you as a direct message.", len(results.StaleChannels), results.ExitReason)
 	}
 
 	if list {
 		ca.reportChannelList(args, results.ChannelsArchived)
-		msg := fmt.Sprintf("count: %d\n%s", len(results.ChannelsArchived), results.ExitReason)
-		return msg, nil
+		return fmt.Sprintf("count: %d\n%s", len(results.ChannelsArchived), results.ExitReason)
+	}
+
+	return fmt.Sprintf("%d channels archived in %v (run ID %s).\n%s",
+		len(results.ChannelsArchived), results.Duration, results.RunID, results.ExitReason)
+}
+
+func (ca *ChannelArchiverCmd) handleRun(args *model.CommandArgs) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return fmt.Sprintf("You require %s permissions to execute this command.", model.PermissionManageSystem.Id), nil
+	}
+
+	var policyName string
+	if split := strings.Fields(args.Command); len(split) >= 3 {
+		policyName = split[2]
 	}
 
-	return fmt.Sprintf("%d channels archived in %v.\n%s",
-		len(results.ChannelsArchived), results.Duration, results.ExitReason), nil
+	job, err := jobs.FindChannelArchiverJob(ca.jobManager, policyName)
+	if err != nil {
+		return fmt.Sprintf("Cannot run the archiver job: %s.", err.Error()), nil
+	}
+
+	if err := job.RunNow(args.UserId); err != nil {
+		return fmt.Sprintf("Cannot run the archiver job: %s", err.Error()), nil
+	}
+	return fmt.Sprintf("The archiver job `%s` has started. Use `/%s status` to check on it; the run summary is posted to the report channel, if configured.", job.GetID(), ArchiverTrigger), nil
+}
+
+func (ca *ChannelArchiverCmd) handleStatus(args *model.CommandArgs) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return fmt.Sprintf("You require %s permissions to execute this command.", model.PermissionManageSystem.Id), nil
+	}
+
+	var sb strings.Builder
+	ca.writeJobStatus(&sb)
+
+	runs := ca.runs.list()
+	if len(runs) == 0 {
+		sb.WriteString("No manual runs in progress on this server.\n")
+		sb.WriteString(runsOnThisServerNote + "\n")
+		return sb.String(), nil
+	}
+
+	sb.WriteString("#### Manual runs in progress on this server\n")
+	sb.WriteString(runsOnThisServerNote + "\n")
+	for _, run := range runs {
+		startedBy := run.userID
+		if user, err := ca.client.User.Get(run.userID); err == nil {
+			startedBy = "@" + user.Username
+		}
+		sb.WriteString(fmt.Sprintf("- `%s` %s started by %s %s ago", run.id, run.action, startedBy, time.Since(run.startedAt).Round(time.Second)))
+		if run.action == runActionArchive {
+			sb.WriteString(fmt.Sprintf(", %d channels archived", run.getArchived()))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+func (ca *ChannelArchiverCmd) handleCancel(args *model.CommandArgs) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return fmt.Sprintf("You require %s permissions to execute this command.", model.PermissionManageSystem.Id), nil
+	}
+
+	split := strings.Fields(args.Command)
+	if len(split) < 3 {
+		return fmt.Sprintf("Please specify the ID of the run to cancel. Use `/%s status` to see runs in progress.", ArchiverTrigger), nil
+	}
+	runID := split[2]
+
+	run, ok := ca.runs.get(runID)
+	if !ok {
+		return fmt.Sprintf("No run in progress with ID %s on this server. %s", runID, runsOnThisServerNote), nil
+	}
+
+	// the run reports its results, including that it was canceled, once it has stopped.
+	run.cancel()
+	return fmt.Sprintf("Canceling run %s. You will be notified when it stops.", runID), nil
+}
+
+func (ca *ChannelArchiverCmd) handleRestore(args *model.CommandArgs, params map[string]string) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return fmt.Sprintf("You require %s permissions to execute this command.", model.PermissionManageSystem.Id), nil
+	}
+
+	var channelIDs []string
+
+	if name, ok := params[paramNameChannel]; ok {
+		ch, err := ca.getChannel(args.TeamId, name)
+		if err != nil {
+			return fmt.Sprintf("Cannot find channel '%s': %s", name, err.Error()), nil
+		}
+		channelIDs = append(channelIDs, ch.Id)
+	} else {
+		opts := store.ArchivedChannelOpts{
+			RunID: params[paramNameRun],
+		}
+
+		if from, ok := params[paramNameFrom]; ok {
+			t, err := time.Parse(dateLayout, from)
+			if err != nil {
+				return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameFrom, err.Error()), nil
+			}
+			opts.ArchivedAfter = model.GetMillisForTime(t)
+		}
+
+		if to, ok := params[paramNameTo]; ok {
+			t, err := time.Parse(dateLayout, to)
+			if err != nil {
+				return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameTo, err.Error()), nil
+			}
+			// include the whole day
+			opts.ArchivedBefore = model.GetMillisForTime(t.AddDate(0, 0, 1))
+		}
+
+		if opts == (store.ArchivedChannelOpts{}) {
+			return fmt.Sprintf("Please specify '%s', '%s', or a date range using '%s' and/or '%s'.", paramNameChannel, paramNameRun, paramNameFrom, paramNameTo), nil
+		}
+
+		states, err := ca.sqlStore.GetArchivedChannelStates(opts)
+		if err != nil {
+			return fmt.Sprintf("Error fetching archived channels: %s", err.Error()), nil
+		}
+		for _, state := range states {
+			channelIDs = append(channelIDs, state.ChannelID)
+		}
+	}
+
+	if len(channelIDs) == 0 {
+		return "No archived channels found.", nil
+	}
+
+	run := newManualRun(model.NewId(), args.UserId, runActionRestore)
+	ca.startRun(args, run, func(ctx context.Context) string {
+		results := channels.RestoreChannels(ctx, ca.sqlStore, ca.client, ca.bot, channelIDs)
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("%d channels restored (run ID %s).\n%s", len(results.ChannelsRestored), run.id, results.ExitReason))
+		for _, e := range results.Errors {
+			sb.WriteString("\n")
+			sb.WriteString(e)
+		}
+		return sb.String()
+	})
+
+	return fmt.Sprintf("Restoring %d channels in the background (run ID %s).\nUse `/%s status` to see runs in progress, or `/%s cancel %s` to stop this run.",
+		len(channelIDs), run.id, ArchiverTrigger, ArchiverTrigger, run.id), nil
+}
+
+func (ca *ChannelArchiverCmd) handleKeep(args *model.CommandArgs, params map[string]string) (string, error) {
+	if !ca.client.User.HasPermissionToChannel(args.UserId, args.ChannelId, model.PermissionManageChannelRoles) {
+		return "You must be a channel admin to execute this command.", nil
+	}
+
+	if _, ok := params[paramNameRemove]; ok {
+		if err := ca.sqlStore.DeleteKeepAlive(args.ChannelId); err != nil {
+			return fmt.Sprintf("Error removing exemption: %s", err.Error()), nil
+		}
+		return "This channel can be auto-archived again.", nil
+	}
+
+	keepAlive := &store.KeepAlive{
+		ChannelID: args.ChannelId,
+		UserID:    args.UserId,
+		CreateAt:  model.GetMillis(),
+	}
+
+	if until, ok := params[paramNameUntil]; ok {
+		t, err := time.Parse(dateLayout, until)
+		if err != nil {
+			return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameUntil, err.Error()), nil
+		}
+		// keep through the end of the day
+		t = t.AddDate(0, 0, 1)
+		if t.Before(time.Now()) {
+			return fmt.Sprintf("Invalid '%s' parameter: date must not be in the past", paramNameUntil), nil
+		}
+		keepAlive.ExpireAt = model.GetMillisForTime(t)
+	}
+
+	if err := ca.sqlStore.SaveKeepAlive(keepAlive); err != nil {
+		return fmt.Sprintf("Error exempting channel: %s", err.Error()), nil
+	}
+
+	if keepAlive.ExpireAt == 0 {
+		return "This channel will not be auto-archived.", nil
+	}
+	return fmt.Sprintf("This channel will not be auto-archived until %s.", params[paramNameUntil]), nil
+}
+
+// getChannel fetches a channel, including archived channels, by ID or by name within the team.
+func (ca *ChannelArchiverCmd) getChannel(teamID string, nameOrID string) (*model.Channel, error) {
+	nameOrID = strings.TrimPrefix(nameOrID, "~")
+	if model.IsValidId(nameOrID) {
+		if ch, err := ca.client.Channel.Get(nameOrID); err == nil {
+			return ch, nil
+		}
+	}
+	return ca.client.Channel.GetByName(teamID, nameOrID, true)
 }
 
 func (ca *ChannelArchiverCmd) handleHelp() (string, error) {
@@ -196,6 +573,15 @@ func (ca *ChannelArchiverCmd) handleHelp() (string, error) {
 	return resp, nil
 }
 
+func (ca *ChannelArchiverCmd) exportChannelList(args *model.CommandArgs, staleChannels []*store.StaleChannel, format channels.ExportFormat) error {
+	data, err := channels.ExportStaleChannels(staleChannels, format)
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("Stale channels export (%d channels)", len(staleChannels))
+	return ca.bot.SendDirectPostWithFile(args.UserId, msg, channels.ExportFileName(format, time.Now()), data)
+}
+
 func (ca *ChannelArchiverCmd) reportChannelList(args *model.CommandArgs, channelIDs []string) {
 	total := len(channelIDs)
 	const itemsPerPost = 500
@@ -0,0 +1,126 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+const (
+	historyPageSize   = 20
+	historyTimeLayout = "2006-01-02 15:04 MST"
+)
+
+func (ca *ChannelArchiverCmd) handleHistory(args *model.CommandArgs, params map[string]string) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return fmt.Sprintf("You require %s permissions to execute this command.", model.PermissionManageSystem.Id), nil
+	}
+
+	if runID, ok := params[paramNameRun]; ok {
+		run, err := ca.sqlStore.GetRunHistoryByID(runID)
+		if err != nil {
+			return fmt.Sprintf("Error fetching run history: %s", err.Error()), nil
+		}
+		if run == nil {
+			return fmt.Sprintf("No run found with ID %s.", runID), nil
+		}
+		return formatRunDetails(run, ca.triggeredByName(run.TriggeredBy)), nil
+	}
+
+	var page int
+	if p, ok := params[paramNamePage]; ok {
+		var err error
+		if page, err = config.ParseInt(p, 0, math.MaxInt32); err != nil {
+			return fmt.Sprintf("Invalid '%s' parameter: %s", paramNamePage, err.Error()), nil
+		}
+	}
+
+	runs, more, err := ca.sqlStore.GetRunHistory(page, historyPageSize)
+	if err != nil {
+		return fmt.Sprintf("Error fetching run history: %s", err.Error()), nil
+	}
+	if len(runs) == 0 {
+		return "No runs found.", nil
+	}
+
+	names := make(map[string]string)
+	for _, run := range runs {
+		if _, ok := names[run.TriggeredBy]; !ok {
+			names[run.TriggeredBy] = ca.triggeredByName(run.TriggeredBy)
+		}
+	}
+
+	msg := formatRunHistory(runs, names)
+	if more {
+		msg += fmt.Sprintf("\nUse `/%s history --%s %d` to see older runs.", ArchiverTrigger, paramNamePage, page+1)
+	}
+	return msg, nil
+}
+
+// triggeredByName returns a display name for who triggered a run.
+func (ca *ChannelArchiverCmd) triggeredByName(triggeredBy string) string {
+	if triggeredBy == store.TriggeredBySchedule || triggeredBy == "" {
+		return triggeredBy
+	}
+	if user, err := ca.client.User.Get(triggeredBy); err == nil {
+		return "@" + user.Username
+	}
+	return triggeredBy
+}
+
+func runType(run *store.RunHistory) string {
+	if run.ListOnly {
+		return "list"
+	}
+	return "archive"
+}
+
+func runDuration(run *store.RunHistory) time.Duration {
+	return (time.Duration(run.EndAt-run.StartAt) * time.Millisecond).Round(time.Second)
+}
+
+// formatRunHistory returns a markdown table of runs.
+func formatRunHistory(runs []*store.RunHistory, names map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString("| Run ID | Started | Duration | Type | Policy | Triggered by | Stale | Archived | Warned | Result |\n")
+	sb.WriteString("|---|---|---|---|---|---|---|---|---|---|\n")
+	for _, run := range runs {
+		sb.WriteString(fmt.Sprintf("| `%s` | %s | %s | %s | %s | %s | %d | %d | %d | %s |\n",
+			run.RunID, model.GetTimeForMillis(run.StartAt).UTC().Format(historyTimeLayout), runDuration(run), runType(run),
+			run.Policy, names[run.TriggeredBy], run.StaleCount, run.ArchivedCount, run.WarnedCount, run.ExitReason))
+	}
+	return sb.String()
+}
+
+// formatRunDetails returns a markdown description of a run.
+func formatRunDetails(run *store.RunHistory, triggeredBy string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("#### Run `%s`\n", run.RunID))
+	sb.WriteString(fmt.Sprintf("- Type: %s\n", runType(run)))
+	if run.Policy != "" {
+		sb.WriteString(fmt.Sprintf("- Policy: %s\n", run.Policy))
+	}
+	sb.WriteString(fmt.Sprintf("- Triggered by: %s\n", triggeredBy))
+	sb.WriteString(fmt.Sprintf("- Started: %s\n", model.GetTimeForMillis(run.StartAt).UTC().Format(historyTimeLayout)))
+	sb.WriteString(fmt.Sprintf("- Duration: %s\n", runDuration(run)))
+	sb.WriteString(fmt.Sprintf("- Stale channels: %d\n", run.StaleCount))
+	sb.WriteString(fmt.Sprintf("- Archived: %d\n", run.ArchivedCount))
+	sb.WriteString(fmt.Sprintf("- Warned: %d\n", run.WarnedCount))
+	sb.WriteString(fmt.Sprintf("- Result: %s\n", run.ExitReason))
+	if run.ErrorMessage != "" {
+		sb.WriteString(fmt.Sprintf("- Error: %s\n", run.ErrorMessage))
+	}
+	if run.Settings != "" {
+		sb.WriteString(fmt.Sprintf("- Settings:\n```\n%s\n```\n", run.Settings))
+	}
+	if !run.ListOnly && run.ArchivedCount > 0 {
+		sb.WriteString(fmt.Sprintf("\nUse `/%s restore --%s %s` to restore the channels archived by this run.\n", ArchiverTrigger, paramNameRun, run.RunID))
+	}
+	return sb.String()
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+func TestFormatRunHistory(t *testing.T) {
+	start := model.GetMillisForTime(time.Date(2022, 6, 10, 1, 0, 0, 0, time.UTC))
+	runs := []*store.RunHistory{
+		{RunID: "run1", Policy: "tmp", TriggeredBy: store.TriggeredBySchedule, StartAt: start, EndAt: start + 90500, StaleCount: 4, ArchivedCount: 3, WarnedCount: 1, ExitReason: "completed normally"},
+		{RunID: "run2", TriggeredBy: "user1", ListOnly: true, StartAt: start, EndAt: start, StaleCount: 7, ExitReason: "canceled"},
+	}
+	names := map[string]string{store.TriggeredBySchedule: store.TriggeredBySchedule, "user1": "@alice"}
+
+	s := formatRunHistory(runs, names)
+	assert.Contains(t, s, "| `run1` | 2022-06-10 01:00 UTC | 1m31s | archive | tmp | schedule | 4 | 3 | 1 | completed normally |\n")
+	assert.Contains(t, s, "| `run2` | 2022-06-10 01:00 UTC | 0s | list |  | @alice | 7 | 0 | 0 | canceled |\n")
+}
+
+func TestFormatRunDetails(t *testing.T) {
+	run := &store.RunHistory{
+		RunID:         "run1",
+		TriggeredBy:   "user1",
+		ArchivedCount: 3,
+		ExitReason:    "error",
+		ErrorMessage:  "boom",
+		Settings:      `{"AgeInDays":30}`,
+	}
+
+	s := formatRunDetails(run, "@alice")
+	assert.Contains(t, s, "- Type: archive\n")
+	assert.NotContains(t, s, "- Policy:")
+	assert.Contains(t, s, "- Triggered by: @alice\n")
+	assert.Contains(t, s, "- Error: boom\n")
+	assert.Contains(t, s, "{\"AgeInDays\":30}")
+	assert.Contains(t, s, "/channel-archiver restore --run run1")
+}
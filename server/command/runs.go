@@ -0,0 +1,158 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wiggin77/merror"
+)
+
+// runAction is what a manual run does.
+type runAction string
+
+const (
+	runActionArchive runAction = "archive"
+	runActionList    runAction = "list"
+	runActionRestore runAction = "restore"
+)
+
+// manualRun is an archive, list or restore run started by the slash command.
+type manualRun struct {
+	id        string
+	userID    string
+	action    runAction
+	startedAt time.Time
+	archived  int64 // number of channels archived so far; accessed atomically
+
+	ctx        context.Context // canceled when the run is stopped
+	canceller  func()          // called to stop the run
+	exitSignal chan struct{}   // closed when the run has exited
+}
+
+// newManualRun creates a run. The run outlives the command that starts it, so it gets its own
+// context which is canceled by the cancel subcommand or when the plugin is deactivated.
+func newManualRun(id string, userID string, action runAction) *manualRun {
+	ctx, canceller := context.WithCancel(context.Background())
+	return &manualRun{
+		id:         id,
+		userID:     userID,
+		action:     action,
+		startedAt:  time.Now(),
+		ctx:        ctx,
+		canceller:  canceller,
+		exitSignal: make(chan struct{}),
+	}
+}
+
+func (r *manualRun) setArchived(count int) {
+	atomic.StoreInt64(&r.archived, int64(count))
+}
+
+func (r *manualRun) getArchived() int {
+	return int(atomic.LoadInt64(&r.archived))
+}
+
+// cancel asks the run to stop without waiting for it to exit.
+func (r *manualRun) cancel() {
+	r.canceller()
+}
+
+// stop cancels the run and waits for it to exit.
+func (r *manualRun) stop(timeout time.Duration) error {
+	r.cancel()
+
+	deadline, stopTimer := newDeadline(timeout)
+	defer stopTimer()
+	return r.wait(deadline, timeout)
+}
+
+// wait waits for the run to exit or the deadline to be closed, whichever comes first.
+func (r *manualRun) wait(deadline <-chan struct{}, timeout time.Duration) error {
+	select {
+	case <-r.exitSignal:
+		return nil
+	case <-deadline:
+		return fmt.Errorf("waiting on run %s to stop timed out after %s", r.id, timeout.String())
+	}
+}
+
+// runsOnThisServerNote is shown with run status and cancel results.
+const runsOnThisServerNote = "Only runs started on this server are shown; in a cluster, runs started on other servers must be checked or canceled from a slash command handled by that server."
+
+// runRegistry tracks the manual runs in progress on this server so they can be listed and
+// canceled. Runs started on other servers in a cluster are not visible.
+type runRegistry struct {
+	mux  sync.Mutex
+	runs map[string]*manualRun
+}
+
+func newRunRegistry() *runRegistry {
+	return &runRegistry{
+		runs: make(map[string]*manualRun),
+	}
+}
+
+func (rr *runRegistry) add(run *manualRun) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	rr.runs[run.id] = run
+}
+
+func (rr *runRegistry) remove(id string) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	delete(rr.runs, id)
+}
+
+func (rr *runRegistry) get(id string) (*manualRun, bool) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	run, ok := rr.runs[id]
+	return run, ok
+}
+
+// list returns the runs in progress, oldest first.
+func (rr *runRegistry) list() []*manualRun {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+
+	runs := make([]*manualRun, 0, len(rr.runs))
+	for _, run := range rr.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].startedAt.Before(runs[j].startedAt)
+	})
+	return runs
+}
+
+// stopAll cancels all runs in progress and waits up to timeout in total for them to exit.
+func (rr *runRegistry) stopAll(timeout time.Duration) error {
+	runs := rr.list()
+	for _, run := range runs {
+		run.cancel()
+	}
+
+	deadline, stopTimer := newDeadline(timeout)
+	defer stopTimer()
+
+	merr := merror.New()
+	for _, run := range runs {
+		if err := run.wait(deadline, timeout); err != nil {
+			merr.Append(err)
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+// newDeadline returns a channel that is closed after timeout, so that any number of waiters
+// see it, and a function to stop the timer.
+func newDeadline(timeout time.Duration) (<-chan struct{}, func() bool) {
+	deadline := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(deadline) })
+	return deadline, timer.Stop
+}
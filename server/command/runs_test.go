@@ -0,0 +1,72 @@
+package command
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRegistry(t *testing.T) {
+	rr := newRunRegistry()
+
+	run1 := newManualRun("run1", "user1", runActionArchive)
+	run2 := newManualRun("run2", "user1", runActionList)
+	run2.startedAt = run1.startedAt.Add(time.Second)
+	rr.add(run2)
+	rr.add(run1)
+
+	runs := rr.list()
+	require.Len(t, runs, 2)
+	assert.Equal(t, "run1", runs[0].id)
+	assert.Equal(t, "run2", runs[1].id)
+
+	run, ok := rr.get("run1")
+	require.True(t, ok)
+
+	// simulate the run exiting once canceled
+	go func() {
+		<-run.ctx.Done()
+		rr.remove(run.id)
+		close(run.exitSignal)
+	}()
+	require.NoError(t, run.stop(time.Second))
+
+	_, ok = rr.get("run1")
+	assert.False(t, ok)
+	assert.Len(t, rr.list(), 1)
+
+	// a run that doesn't exit times out
+	assert.Error(t, run2.stop(time.Millisecond*10))
+}
+
+func TestRunRegistryStopAll(t *testing.T) {
+	rr := newRunRegistry()
+
+	runs := make([]*manualRun, 3)
+	for i := range runs {
+		runs[i] = newManualRun(fmt.Sprintf("run%d", i), "user1", runActionArchive)
+		rr.add(runs[i])
+	}
+
+	// the first run exits when canceled, the others never do.
+	go func() {
+		<-runs[0].ctx.Done()
+		close(runs[0].exitSignal)
+	}()
+
+	start := time.Now()
+	err := rr.stopAll(time.Millisecond * 100)
+	require.Error(t, err)
+
+	// every run is canceled up front and the timeout applies to all of them together.
+	assert.Less(t, time.Since(start), time.Millisecond*250)
+	for _, run := range runs {
+		assert.Error(t, run.ctx.Err())
+	}
+	assert.NotContains(t, err.Error(), "run0")
+	assert.Contains(t, err.Error(), "run1")
+	assert.Contains(t, err.Error(), "run2")
+}
@@ -0,0 +1,73 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/jobs"
+)
+
+// writeJobStatus writes the state of each scheduled Channel Archiver job.
+func (ca *ChannelArchiverCmd) writeJobStatus(sb *strings.Builder) {
+	var archiverJobs []*jobs.ChannelArchiverJob
+	for _, job := range ca.jobManager.GetJobs() {
+		if archiverJob, ok := job.(*jobs.ChannelArchiverJob); ok {
+			archiverJobs = append(archiverJobs, archiverJob)
+		}
+	}
+	sort.Slice(archiverJobs, func(i, j int) bool {
+		return archiverJobs[i].GetID() < archiverJobs[j].GetID()
+	})
+
+	sb.WriteString("#### Scheduled jobs\n")
+	if len(archiverJobs) == 0 {
+		sb.WriteString("No scheduled jobs.\n")
+	}
+	for _, job := range archiverJobs {
+		status, err := job.Status()
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("- `%s`: error fetching status: %s\n", job.GetID(), err.Error()))
+			continue
+		}
+		sb.WriteString(formatJobStatus(job.GetID(), status, time.Now()))
+	}
+}
+
+// formatJobStatus returns a markdown description of a scheduled job's state.
+func formatJobStatus(jobID string, status *jobs.ChannelArchiverJobStatus, now time.Time) string {
+	var sb strings.Builder
+
+	name := status.Settings.PolicyName
+	if name == "" {
+		name = "default"
+	}
+	sb.WriteString(fmt.Sprintf("- **%s** policy (`%s`): ", name, jobID))
+	if !status.Settings.EnableChannelArchiver {
+		sb.WriteString("disabled\n")
+		return sb.String()
+	}
+	sb.WriteString("enabled")
+	if status.Running {
+		sb.WriteString(", running now on this server")
+	}
+	sb.WriteString("\n")
+
+	lastFinished := "never"
+	if !status.LastFinished.IsZero() {
+		lastFinished = fmt.Sprintf("%s (%s ago)", status.LastFinished.Format(jobs.FullLayout), now.Sub(status.LastFinished).Round(time.Minute))
+	}
+	sb.WriteString(fmt.Sprintf("  - Last finished: %s\n", lastFinished))
+
+	nextRun := status.NextRun.Format(jobs.FullLayout)
+	if status.NextRun.Before(now) {
+		nextRun += " (due now)"
+	} else {
+		nextRun += fmt.Sprintf(" (in %s)", status.NextRun.Sub(now).Round(time.Minute))
+	}
+	sb.WriteString(fmt.Sprintf("  - Next run: %s\n", nextRun))
+	sb.WriteString(fmt.Sprintf("  - Settings: `%s`\n", status.Settings.String()))
+
+	return sb.String()
+}
@@ -0,0 +1,48 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/jobs"
+)
+
+func TestFormatJobStatus(t *testing.T) {
+	now := time.Date(2022, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("disabled", func(t *testing.T) {
+		status := &jobs.ChannelArchiverJobStatus{
+			Settings: &jobs.ChannelArchiverJobSettings{PolicyName: "tmp"},
+		}
+		assert.Equal(t, "- **tmp** policy (`channel_archiver_job_tmp`): disabled\n", formatJobStatus("channel_archiver_job_tmp", status, now))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		status := &jobs.ChannelArchiverJobStatus{
+			Settings: &jobs.ChannelArchiverJobSettings{
+				EnableChannelArchiver: true,
+				Frequency:             jobs.Daily,
+			},
+			NextRun: now.Add(time.Hour * 3),
+			Running: true,
+		}
+		s := formatJobStatus("channel_archiver_job", status, now)
+		assert.Contains(t, s, "- **default** policy (`channel_archiver_job`): enabled, running now on this server\n")
+		assert.Contains(t, s, "  - Last finished: never\n")
+		assert.Contains(t, s, "  - Next run: Jun 10, 2022 3:00pm +0000 (in 3h0m0s)\n")
+		assert.Contains(t, s, "freq=daily")
+	})
+
+	t.Run("overdue", func(t *testing.T) {
+		status := &jobs.ChannelArchiverJobStatus{
+			Settings:     &jobs.ChannelArchiverJobSettings{EnableChannelArchiver: true},
+			LastFinished: now.AddDate(0, 0, -8),
+			NextRun:      now.Add(-time.Minute),
+		}
+		s := formatJobStatus("channel_archiver_job", status, now)
+		assert.Contains(t, s, "  - Last finished: Jun 2, 2022 12:00pm +0000 (192h0m0s ago)\n")
+		assert.Contains(t, s, "(due now)")
+	})
+}
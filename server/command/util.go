@@ -1,7 +1,10 @@
 package command
 
 import (
+	"fmt"
 	"strings"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
 )
 
 const (
@@ -37,6 +40,36 @@ func parseNamedArgs(cmd string) map[string]string {
 	return m
 }
 
+// parseChannelTypes parses a comma separated list of channel types (public, private, direct,
+// group) into the stale channel options.
+func parseChannelTypes(s string, opts *store.StaleChannelOpts) error {
+	opts.IncludeChannelTypeOpen = false
+	opts.IncludeChannelTypePrivate = false
+	opts.IncludeChannelTypeDirect = false
+	opts.IncludeChannelTypeGroup = false
+
+	for _, t := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(t)) {
+		case "public":
+			opts.IncludeChannelTypeOpen = true
+		case "private":
+			opts.IncludeChannelTypePrivate = true
+		case "direct":
+			opts.IncludeChannelTypeDirect = true
+		case "group":
+			opts.IncludeChannelTypeGroup = true
+		case "":
+		default:
+			return fmt.Errorf("unknown channel type '%s', must be public, private, direct or group", t)
+		}
+	}
+
+	if !opts.IncludeChannelTypeOpen && !opts.IncludeChannelTypePrivate && !opts.IncludeChannelTypeDirect && !opts.IncludeChannelTypeGroup {
+		return fmt.Errorf("at least one channel type is required")
+	}
+	return nil
+}
+
 func trimSpaceAndQuotes(s string) string {
 	trimmed := strings.TrimSpace(s)
 	trimmed = strings.TrimPrefix(trimmed, "\"")
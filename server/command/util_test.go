@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
 )
 
 func TestParseNamedArgs(t *testing.T) {
@@ -35,3 +38,20 @@ func TestParseNamedArgs(t *testing.T) {
 		assert.Equal(t, tt.m, m, tt.name)
 	}
 }
+
+func TestParseChannelTypes(t *testing.T) {
+	var opts store.StaleChannelOpts
+
+	require.NoError(t, parseChannelTypes("public", &opts))
+	assert.True(t, opts.IncludeChannelTypeOpen)
+	assert.False(t, opts.IncludeChannelTypePrivate)
+
+	require.NoError(t, parseChannelTypes("Private,direct,group", &opts))
+	assert.False(t, opts.IncludeChannelTypeOpen)
+	assert.True(t, opts.IncludeChannelTypePrivate)
+	assert.True(t, opts.IncludeChannelTypeDirect)
+	assert.True(t, opts.IncludeChannelTypeGroup)
+
+	assert.Error(t, parseChannelTypes("", &opts))
+	assert.Error(t, parseChannelTypes("public,archived", &opts))
+}
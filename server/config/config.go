@@ -15,6 +15,13 @@ const (
 	DefaultAgeInDays = 365
 	MinAgeInDays     = 30
 	MaxAgeInDays     = 10000
+
+	DefaultGracePeriodDays = 7
+
+	DefaultUserCleanupDays      = 30
+	MinUserCleanupDays          = 0
+	MaxUserCleanupDays          = 10000
+	DefaultUserCleanupBatchSize = 100
 )
 
 var (
@@ -34,18 +41,35 @@ var (
 // copy appropriate for your types.
 type Configuration struct {
 	EnableChannelArchiver bool
-	AgeInDays             int
-	Frequency             string
-	DayOfWeek             string
-	TimeOfDay             string
-	ExcludeChannels       string
-	BatchSize             int
+
+	// ArchiverPolicy holds the settings of the default Channel Archiver policy, which also serve
+	// as defaults for the named policies in ArchiverPolicies.
+	ArchiverPolicy
+	ArchiverPolicies string
+
+	// Deactivated user clean-up removes users deactivated more than UserCleanupDays ago from all
+	// of their teams and channels.
+	EnableUserCleanup    bool
+	UserCleanupDays      int
+	UserCleanupFrequency string
+	UserCleanupDayOfWeek string
+	UserCleanupTimeOfDay string
+	UserCleanupBatchSize int
 }
 
 func NewConfiguration() *Configuration {
 	return &Configuration{
-		AgeInDays: DefaultAgeInDays,
-		BatchSize: DefaultArchiveBatchSize,
+		ArchiverPolicy: ArchiverPolicy{
+			IncludePublicChannels:  true,
+			IncludePrivateChannels: true,
+			AgeInDays:              DefaultAgeInDays,
+			BatchSize:              DefaultArchiveBatchSize,
+			GracePeriodDays:        DefaultGracePeriodDays,
+			PostArchiveNotice:      true,
+			NotifyOwners:           true,
+		},
+		UserCleanupDays:      DefaultUserCleanupDays,
+		UserCleanupBatchSize: DefaultUserCleanupBatchSize,
 	}
 }
 
@@ -56,6 +80,17 @@ func (c *Configuration) Clone() *Configuration {
 	return &clone
 }
 
+// ValidateMemberLimits checks a minimum and maximum channel member count, where zero means no limit.
+func ValidateMemberLimits(minMembers int, maxMembers int) error {
+	if minMembers < 0 || maxMembers < 0 {
+		return errors.New("member counts cannot be negative")
+	}
+	if maxMembers > 0 && maxMembers < minMembers {
+		return fmt.Errorf("maximum members (%d) cannot be less than minimum members (%d)", maxMembers, minMembers)
+	}
+	return nil
+}
+
 func ParseInt(s string, min int, max int) (int, error) {
 	i64, err := strconv.ParseInt(s, 10, 32)
 	if err != nil {
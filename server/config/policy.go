@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	MaxPolicyNameLength = 32
+)
+
+var (
+	policyNameRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
+)
+
+// ArchiverPolicy is a named set of Channel Archiver criteria, schedule and notification behavior.
+// The default policy has an empty name.
+type ArchiverPolicy struct {
+	Name string
+
+	// criteria
+	IncludePublicChannels  bool
+	IncludePrivateChannels bool
+	AgeInDays              int
+	EmptyOnly              bool
+	AllMembersDeactivated  bool
+	MinMembers             int
+	MaxMembers             int
+	IncludeChannels        string
+	ExcludeChannels        string
+	IncludeTeams           string
+	ExcludeTeams           string
+	TeamAgeInDays          string
+
+	// direct and group message channels
+	DirectChannelAction string
+
+	// schedule
+	Frequency string
+	DayOfWeek string
+	TimeOfDay string
+	BatchSize int
+
+	// notifications
+	GracePeriodDays   int
+	PostArchiveNotice bool
+	NotifyOwners      bool
+	ReportChannel     string // channel ID or team-name/channel-name for run summaries
+	ExportFormat      string // attach the stale channel list to run summaries as csv or json
+}
+
+// GetArchiverPolicies returns the configured Channel Archiver policies. When ArchiverPolicies is
+// empty the default policy is returned; otherwise each named policy starts as a copy of the
+// default policy, overridden by the fields specified for it.
+func (c *Configuration) GetArchiverPolicies() ([]ArchiverPolicy, error) {
+	if strings.TrimSpace(c.ArchiverPolicies) == "" {
+		return []ArchiverPolicy{c.ArchiverPolicy}, nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(c.ArchiverPolicies), &raw); err != nil {
+		return nil, fmt.Errorf("cannot parse `Archiver policies`: %w", err)
+	}
+
+	policies := make([]ArchiverPolicy, 0, len(raw))
+	names := make(map[string]bool)
+
+	for i, r := range raw {
+		policy := c.ArchiverPolicy
+		if err := json.Unmarshal(r, &policy); err != nil {
+			return nil, fmt.Errorf("cannot parse `Archiver policies` entry %d: %w", i+1, err)
+		}
+
+		if len(policy.Name) == 0 || len(policy.Name) > MaxPolicyNameLength || !policyNameRegex.MatchString(policy.Name) {
+			return nil, fmt.Errorf("`Archiver policies` entry %d: name must be 1 to %d lowercase letters, digits, '-' or '_'", i+1, MaxPolicyNameLength)
+		}
+		if names[policy.Name] {
+			return nil, fmt.Errorf("`Archiver policies` entry %d: duplicate name '%s'", i+1, policy.Name)
+		}
+		names[policy.Name] = true
+
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
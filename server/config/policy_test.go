@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetArchiverPolicies(t *testing.T) {
+	t.Run("default only", func(t *testing.T) {
+		cfg := NewConfiguration()
+		policies, err := cfg.GetArchiverPolicies()
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		assert.Equal(t, "", policies[0].Name)
+		assert.Equal(t, DefaultAgeInDays, policies[0].AgeInDays)
+	})
+
+	t.Run("named policies inherit defaults", func(t *testing.T) {
+		cfg := NewConfiguration()
+		cfg.Frequency = "daily"
+		cfg.ArchiverPolicies = `[
+			{"Name": "empty", "AgeInDays": 30, "EmptyOnly": true},
+			{"Name": "sales", "IncludeTeams": "sales", "PostArchiveNotice": false}
+		]`
+
+		policies, err := cfg.GetArchiverPolicies()
+		require.NoError(t, err)
+		require.Len(t, policies, 2)
+
+		assert.Equal(t, "empty", policies[0].Name)
+		assert.Equal(t, 30, policies[0].AgeInDays)
+		assert.True(t, policies[0].EmptyOnly)
+		assert.Equal(t, "daily", policies[0].Frequency)
+		assert.True(t, policies[0].PostArchiveNotice)
+
+		assert.Equal(t, "sales", policies[1].Name)
+		assert.Equal(t, DefaultAgeInDays, policies[1].AgeInDays)
+		assert.Equal(t, "sales", policies[1].IncludeTeams)
+		assert.False(t, policies[1].PostArchiveNotice)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, s := range []string{
+			`{"Name": "x"}`,
+			`[{"AgeInDays": 30}]`,
+			`[{"Name": "Has Spaces"}]`,
+			`[{"Name": "a"}, {"Name": "a"}]`,
+		} {
+			cfg := NewConfiguration()
+			cfg.ArchiverPolicies = s
+			_, err := cfg.GetArchiverPolicies()
+			assert.Error(t, err, s)
+		}
+	})
+}
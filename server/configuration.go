@@ -59,6 +59,9 @@ func (p *Plugin) OnConfigurationChange() error {
 	}
 
 	if p.jobManager != nil {
+		if err := p.syncChannelArchiverJobs(configuration); err != nil {
+			return err
+		}
 		if err := p.jobManager.OnConfigurationChange(configuration); err != nil {
 			return err
 		}
@@ -3,38 +3,140 @@ package jobs
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/wiggin77/merror"
 
 	pluginapi "github.com/mattermost/mattermost-plugin-api"
 	"github.com/mattermost/mattermost-plugin-api/cluster"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+	"github.com/mattermost/mattermost-server/v6/model"
 	"github.com/mattermost/mattermost-server/v6/plugin"
 )
 
+const (
+	DefaultChannelArchiverJobID = "channel_archiver_job"
+
+	// runNowLockTimeout is how long RunNow waits for the cluster mutex before giving up.
+	runNowLockTimeout = time.Second * 5
+)
+
+var (
+	ErrJobDisabled = errors.New("the Channel Archiver job is disabled")
+	ErrJobRunning  = errors.New("a Channel Archiver run is already in progress")
+)
+
+// DefaultPolicyName is the policy run when no policy is named and several are configured.
+const DefaultPolicyName = "default"
+
+// ChannelArchiverJobID returns the job ID for the named Channel Archiver policy.
+func ChannelArchiverJobID(policyName string) string {
+	if policyName == "" {
+		return DefaultChannelArchiverJobID
+	}
+	return DefaultChannelArchiverJobID + "_" + policyName
+}
+
+// GetChannelArchiverJob returns the job for the named Channel Archiver policy, if any.
+func GetChannelArchiverJob(jm *JobManager, policyName string) (*ChannelArchiverJob, bool) {
+	job, ok := jm.GetJob(ChannelArchiverJobID(policyName))
+	if !ok {
+		return nil, false
+	}
+	archiverJob, ok := job.(*ChannelArchiverJob)
+	return archiverJob, ok
+}
+
+// PolicyNotFoundError is returned when no Channel Archiver policy matches the name given.
+type PolicyNotFoundError struct {
+	Name     string   // empty when the default policy was asked for
+	Policies []string // names of the configured policies
+}
+
+func (e *PolicyNotFoundError) Error() string {
+	var sb strings.Builder
+	if e.Name == "" {
+		sb.WriteString("no default archiver policy")
+	} else {
+		fmt.Fprintf(&sb, "no archiver policy named '%s'", e.Name)
+	}
+
+	switch {
+	case len(e.Policies) == 0 && e.Name != "":
+		sb.WriteString("; only the default policy is configured")
+	case len(e.Policies) == 0:
+		sb.WriteString("; no policies are configured")
+	default:
+		fmt.Fprintf(&sb, "; the policies are: %s", strings.Join(e.Policies, ", "))
+	}
+	return sb.String()
+}
+
+// FindChannelArchiverJob returns the job for the named Channel Archiver policy. Once named
+// policies are configured there is no unnamed default job, so an empty name then selects the
+// only policy, or the one named "default". A *PolicyNotFoundError listing the configured
+// policies is returned if there is no such job.
+func FindChannelArchiverJob(jm *JobManager, policyName string) (*ChannelArchiverJob, error) {
+	if job, ok := GetChannelArchiverJob(jm, policyName); ok {
+		return job, nil
+	}
+
+	var archiverJobs []*ChannelArchiverJob
+	for _, job := range jm.GetJobs() {
+		if archiverJob, ok := job.(*ChannelArchiverJob); ok {
+			archiverJobs = append(archiverJobs, archiverJob)
+		}
+	}
+
+	if policyName == "" {
+		if len(archiverJobs) == 1 {
+			return archiverJobs[0], nil
+		}
+		if job, ok := GetChannelArchiverJob(jm, DefaultPolicyName); ok {
+			return job, nil
+		}
+	}
+
+	names := make([]string, 0, len(archiverJobs))
+	for _, job := range archiverJobs {
+		if job.policyName != "" {
+			names = append(names, job.policyName)
+		}
+	}
+	sort.Strings(names)
+	return nil, &PolicyNotFoundError{Name: policyName, Policies: names}
+}
+
 type ChannelArchiverJob struct {
 	mux      sync.Mutex
 	settings *ChannelArchiverJobSettings
 	job      *cluster.Job
 	runner   *runInstance
 
-	id       string
-	papi     plugin.API
-	client   *pluginapi.Client
-	sqlstore *store.SQLStore
+	id         string
+	policyName string
+	papi       plugin.API
+	client     *pluginapi.Client
+	sqlstore   *store.SQLStore
+	bot        *bot.Bot
 }
 
-func NewChannelArchiverJob(id string, api plugin.API, client *pluginapi.Client, sqlstore *store.SQLStore) (*ChannelArchiverJob, error) {
+func NewChannelArchiverJob(policyName string, api plugin.API, client *pluginapi.Client, sqlstore *store.SQLStore, bot *bot.Bot) (*ChannelArchiverJob, error) {
 	return &ChannelArchiverJob{
-		settings: &ChannelArchiverJobSettings{},
-		id:       id,
-		papi:     api,
-		client:   client,
-		sqlstore: sqlstore,
+		settings:   &ChannelArchiverJobSettings{PolicyName: policyName},
+		id:         ChannelArchiverJobID(policyName),
+		policyName: policyName,
+		papi:       api,
+		client:     client,
+		sqlstore:   sqlstore,
+		bot:        bot,
 	}, nil
 }
 
@@ -45,8 +147,27 @@ func (j *ChannelArchiverJob) GetID() string {
 // OnConfigurationChange is called by the job manager whenenver the plugin settings have changed.
 // Stop current job (if any) and start a new job (if enabled) with new settings.
 func (j *ChannelArchiverJob) OnConfigurationChange(cfg *config.Configuration) error {
-	settings, err := parseChannelArchiverJobSettings(cfg)
+	policies, err := cfg.GetArchiverPolicies()
+	if err != nil {
+		return err
+	}
+
+	// a policy that is no longer configured leaves the job disabled until it is removed.
+	policy := &config.ArchiverPolicy{Name: j.policyName}
+	enabled := false
+	for i := range policies {
+		if policies[i].Name == j.policyName {
+			policy = &policies[i]
+			enabled = cfg.EnableChannelArchiver
+			break
+		}
+	}
+
+	settings, err := parseChannelArchiverJobSettings(enabled, policy)
 	if err != nil {
+		if j.policyName != "" {
+			return fmt.Errorf("Channel Archiver policy '%s': %w", j.policyName, err)
+		}
 		return err
 	}
 
@@ -75,7 +196,7 @@ func (j *ChannelArchiverJob) start(settings *ChannelArchiverJobSettings) error {
 	}
 	j.job = job
 
-	j.client.Log.Debug("Channel Archiver started", "dow", settings.DayOfWeek)
+	j.client.Log.Debug("Channel Archiver started", "policy", settings.PolicyName, "dow", settings.DayOfWeek)
 
 	return nil
 }
@@ -107,7 +228,7 @@ func (j *ChannelArchiverJob) Stop(timeout time.Duration) error {
 		}
 	}
 
-	j.client.Log.Debug("Channel Archiver stopped", "err", merr.ErrorOrNil())
+	j.client.Log.Debug("Channel Archiver stopped", "policy", j.policyName, "err", merr.ErrorOrNil())
 
 	return merr.ErrorOrNil()
 }
@@ -123,20 +244,58 @@ func (j *ChannelArchiverJob) getSettings() *ChannelArchiverJobSettings {
 func (j *ChannelArchiverJob) nextWaitInterval(now time.Time, metaData cluster.JobMetadata) time.Duration {
 	settings := j.getSettings()
 
-	lastFinished := metaData.LastFinished
-	if lastFinished.IsZero() {
-		lastFinished = now
-	}
-
-	next := settings.Frequency.CalcNext(lastFinished, settings.DayOfWeek, settings.TimeOfDay)
+	next := nextRun(settings, metaData.LastFinished, now)
 	delta := next.Sub(now)
 
-	j.client.Log.Debug("Channel Archiver next run scheduled", "last", lastFinished.Format(FullLayout), "next", next.Format(FullLayout), "wait", delta.String())
+	j.client.Log.Debug("Channel Archiver next run scheduled", "policy", settings.PolicyName, "last", metaData.LastFinished.Format(FullLayout), "next", next.Format(FullLayout), "wait", delta.String())
 
 	return delta
 }
 
+// RunNow runs the job immediately in the background with its current settings. It holds the
+// same cluster mutex as scheduled runs, so only one run executes at a time across the cluster.
+// The schedule is not affected.
+func (j *ChannelArchiverJob) RunNow(userID string) error {
+	j.mux.Lock()
+	enabled := j.settings.EnableChannelArchiver
+	running := j.runner != nil
+	j.mux.Unlock()
+
+	if !enabled {
+		return ErrJobDisabled
+	}
+	if running {
+		return ErrJobRunning
+	}
+
+	mutex, err := cluster.NewMutex(j.papi, cronKeyPrefix+j.id)
+	if err != nil {
+		return fmt.Errorf("cannot create Channel Archiver job mutex: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), runNowLockTimeout)
+	defer cancel()
+	if err := mutex.LockWithContext(ctx); err != nil {
+		// a scheduled or triggered run holds the mutex somewhere in the cluster.
+		return ErrJobRunning
+	}
+
+	j.client.Log.Info("Channel Archiver job triggered", "policy", j.policyName, "user_id", userID)
+
+	go func() {
+		defer mutex.Unlock()
+		j.runAs(userID)
+	}()
+	return nil
+}
+
+// run is called by the cluster job scheduler.
 func (j *ChannelArchiverJob) run() {
+	j.runAs(store.TriggeredBySchedule)
+}
+
+// runAs runs the job, recording who triggered it in the run history.
+func (j *ChannelArchiverJob) runAs(triggeredBy string) {
 	exitSignal := make(chan struct{})
 	ctx, canceller := context.WithCancel(context.Background())
 
@@ -165,23 +324,115 @@ func (j *ChannelArchiverJob) run() {
 		return
 	}
 
-	opts := channels.ArchiverOpts{
+	opts, err := j.buildArchiverOpts(settings)
+	if err != nil {
+		j.client.Log.Error("Error running Channel Archiver job", "policy", settings.PolicyName, "err", err)
+		j.recordFailedRun(settings, triggeredBy, err)
+		j.postRunSummary(settings, nil, err)
+		return
+	}
+	opts.TriggeredBy = triggeredBy
+
+	results, err := channels.ArchiveStaleChannels(ctx, j.sqlstore, j.client, opts)
+	j.postRunSummary(settings, results, err)
+	if err != nil {
+		j.client.Log.Error("Error running Channel Archiver job", "policy", settings.PolicyName, "err", err)
+		return
+	}
+
+	j.client.Log.Info("Channel Archiver job", "policy", settings.PolicyName, "run_id", results.RunID, "channels_archived", len(results.ChannelsArchived), "channels_warned", len(results.ChannelsWarned), "status", results.ExitReason, "duration", results.Duration.String())
+}
+
+// recordFailedRun adds a run that could not start to the run history. Since team names
+// could not be resolved, its settings list the teams as configured.
+func (j *ChannelArchiverJob) recordFailedRun(settings *ChannelArchiverJobSettings, triggeredBy string, runErr error) {
+	opts := j.archiverOpts(settings, settings.IncludeTeams, settings.ExcludeTeams, settings.TeamAgeInDays)
+	opts.TriggeredBy = triggeredBy
+	channels.RecordFailedRun(j.sqlstore, j.client, opts, runErr)
+}
+
+// buildArchiverOpts converts job settings to archiver options, resolving team names to IDs.
+func (j *ChannelArchiverJob) buildArchiverOpts(settings *ChannelArchiverJobSettings) (channels.ArchiverOpts, error) {
+	includeTeams, err := j.resolveTeamIDs(settings.IncludeTeams)
+	if err != nil {
+		return channels.ArchiverOpts{}, err
+	}
+
+	excludeTeams, err := j.resolveTeamIDs(settings.ExcludeTeams)
+	if err != nil {
+		return channels.ArchiverOpts{}, err
+	}
+
+	teamAge := make(map[string]int, len(settings.TeamAgeInDays))
+	for team, days := range settings.TeamAgeInDays {
+		var teamID string
+		if teamID, err = j.resolveTeamID(team); err != nil {
+			return channels.ArchiverOpts{}, err
+		}
+		teamAge[teamID] = days
+	}
+
+	return j.archiverOpts(settings, includeTeams, excludeTeams, teamAge), nil
+}
+
+// archiverOpts converts job settings to archiver options using the given teams.
+func (j *ChannelArchiverJob) archiverOpts(settings *ChannelArchiverJobSettings, includeTeams []string, excludeTeams []string,
+	teamAge map[string]int) channels.ArchiverOpts {
+	includeDirect := settings.DirectChannelAction != channels.DirectChannelActionNone
+
+	return channels.ArchiverOpts{
 		StaleChannelOpts: store.StaleChannelOpts{
 			AgeInDays:                 settings.AgeInDays,
-			IncludeChannelTypeOpen:    true,
-			IncludeChannelTypePrivate: true,
+			EmptyOnly:                 settings.EmptyOnly,
+			AllMembersDeactivated:     settings.AllMembersDeactivated,
+			MinMembers:                settings.MinMembers,
+			MaxMembers:                settings.MaxMembers,
+			IncludeChannelTypeOpen:    settings.IncludePublic,
+			IncludeChannelTypePrivate: settings.IncludePrivate,
+			IncludeChannelTypeDirect:  includeDirect,
+			IncludeChannelTypeGroup:   includeDirect,
+			IncludeChannels:           settings.IncludeChannels,
 			ExcludeChannels:           settings.ExcludeChannels,
+			IncludeTeams:              includeTeams,
+			ExcludeTeams:              excludeTeams,
+			TeamAgeInDays:             teamAge,
 		},
-		BatchSize: settings.BatchSize,
+		PolicyName:          settings.PolicyName,
+		BatchSize:           settings.BatchSize,
+		GracePeriodDays:     settings.GracePeriodDays,
+		NoArchiveNotice:     !settings.PostArchiveNotice,
+		NotifyOwners:        settings.NotifyOwners,
+		DirectChannelAction: settings.DirectChannelAction,
+		Preferences:         j.papi,
+		Bot:                 j.bot,
 	}
+}
 
-	results, err := channels.ArchiveStaleChannels(ctx, j.sqlstore, j.client, opts)
-	if err != nil {
-		j.client.Log.Error("Error running Channel Archiver job: %w", err)
-		return
+func (j *ChannelArchiverJob) resolveTeamIDs(teams []string) ([]string, error) {
+	teamIDs := make([]string, 0, len(teams))
+	for _, team := range teams {
+		teamID, err := j.resolveTeamID(team)
+		if err != nil {
+			return nil, err
+		}
+		teamIDs = append(teamIDs, teamID)
+	}
+	return teamIDs, nil
+}
+
+// resolveTeamID returns the ID of a team specified by name or ID.
+func (j *ChannelArchiverJob) resolveTeamID(team string) (string, error) {
+	if model.IsValidId(team) {
+		if t, err := j.client.Team.Get(team); err == nil {
+			return t.Id, nil
+		}
 	}
 
-	j.client.Log.Info("Channel Archiver job", "channels_archived", len(results.ChannelsArchived), "status", results.ExitReason, "duration", results.Duration.String())
+	t, err := j.client.Team.GetByName(team)
+	if err != nil {
+		return "", fmt.Errorf("cannot find team '%s': %w", team, err)
+	}
+	return t.Id, nil
 }
 
 type runInstance struct {
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
+)
+
+const (
+	reportItemsPerPost = 500
+)
+
+// postRunSummary posts a summary of a run to the report channel, with the full lists of warned
+// and archived channels as replies, followed by an export of the stale channels if configured.
+func (j *ChannelArchiverJob) postRunSummary(settings *ChannelArchiverJobSettings, results *channels.ArchiverResults, runErr error) {
+	if settings.ReportChannel == "" || j.bot == nil {
+		return
+	}
+
+	channelID, err := j.resolveChannelID(settings.ReportChannel)
+	if err != nil {
+		j.client.Log.Error("Cannot post Channel Archiver run summary", "policy", settings.PolicyName, "err", err)
+		return
+	}
+
+	msgs := formatRunSummary(settings.PolicyName, results, runErr)
+	if err := j.bot.SendThread(channelID, msgs); err != nil {
+		j.client.Log.Error("Cannot post Channel Archiver run summary", "policy", settings.PolicyName, "err", err)
+		return
+	}
+
+	if settings.ExportFormat == channels.ExportFormatNone || results == nil || len(results.StaleChannels) == 0 {
+		return
+	}
+	data, err := channels.ExportStaleChannels(results.StaleChannels, settings.ExportFormat)
+	if err == nil {
+		msg := fmt.Sprintf("Stale channels export for run `%s` (%d channels)", results.RunID, len(results.StaleChannels))
+		err = j.bot.SendPostWithFile(channelID, msg, channels.ExportFileName(settings.ExportFormat, time.Now()), data)
+	}
+	if err != nil {
+		j.client.Log.Error("Cannot post Channel Archiver export", "policy", settings.PolicyName, "err", err)
+	}
+}
+
+// resolveChannelID returns the ID of a channel specified as an ID or as `team-name/channel-name`.
+func (j *ChannelArchiverJob) resolveChannelID(channel string) (string, error) {
+	if teamName, channelName, ok := strings.Cut(channel, "/"); ok {
+		ch, err := j.client.Channel.GetByNameForTeamName(teamName, strings.TrimPrefix(channelName, "~"), false)
+		if err != nil {
+			return "", fmt.Errorf("cannot find channel '%s': %w", channel, err)
+		}
+		return ch.Id, nil
+	}
+
+	ch, err := j.client.Channel.Get(channel)
+	if err != nil {
+		return "", fmt.Errorf("cannot find channel '%s': %w", channel, err)
+	}
+	return ch.Id, nil
+}
+
+// formatRunSummary returns the summary message for a run followed by messages listing the
+// warned and archived channels. results may be nil if the run could not start.
+func formatRunSummary(policyName string, results *channels.ArchiverResults, runErr error) []string {
+	if results == nil {
+		results = &channels.ArchiverResults{ExitReason: channels.ReasonError}
+	}
+
+	title := "Channel Archiver run summary"
+	if policyName != "" {
+		title = fmt.Sprintf("Channel Archiver run summary for policy `%s`", policyName)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#### %s\n", title)
+	if results.RunID != "" {
+		fmt.Fprintf(&sb, "- Run ID: `%s`\n", results.RunID)
+	}
+	fmt.Fprintf(&sb, "- Status: %s\n", results.ExitReason)
+	fmt.Fprintf(&sb, "- Duration: %s\n", results.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&sb, "- Channels archived: %d\n", len(results.ChannelsArchived))
+	fmt.Fprintf(&sb, "- Channels warned: %d\n", len(results.ChannelsWarned))
+	if runErr != nil {
+		fmt.Fprintf(&sb, "- Error: %s\n", runErr.Error())
+	}
+	if len(results.ChannelsArchived)+len(results.ChannelsWarned) > 0 {
+		sb.WriteString("\nThe full lists of channels are in the replies to this post.")
+	}
+
+	msgs := []string{sb.String()}
+	msgs = append(msgs, formatChannelList("Archived channels", results.ChannelsArchived)...)
+	msgs = append(msgs, formatChannelList("Warned channels", results.ChannelsWarned)...)
+	return msgs
+}
+
+// formatChannelList splits a list of channels into messages of at most reportItemsPerPost items.
+func formatChannelList(title string, list []string) []string {
+	msgs := make([]string, 0)
+	for start := 0; start < len(list); start += reportItemsPerPost {
+		end := start + reportItemsPerPost
+		if end > len(list) {
+			end = len(list)
+		}
+		msgs = append(msgs, fmt.Sprintf("%s %d to %d of %d\n%s", title, start+1, end, len(list), strings.Join(list[start:end], "\n")))
+	}
+	return msgs
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
+)
+
+func TestFormatRunSummary(t *testing.T) {
+	t.Run("no channels", func(t *testing.T) {
+		results := &channels.ArchiverResults{
+			RunID:      "run1",
+			ExitReason: channels.ReasonDone,
+			Duration:   time.Second * 3,
+		}
+		msgs := formatRunSummary("", results, nil)
+		require.Len(t, msgs, 1)
+		assert.Contains(t, msgs[0], "Run ID: `run1`")
+		assert.Contains(t, msgs[0], "Duration: 3s")
+		assert.Contains(t, msgs[0], "Channels archived: 0")
+		assert.NotContains(t, msgs[0], "Error")
+	})
+
+	t.Run("channel lists", func(t *testing.T) {
+		results := &channels.ArchiverResults{
+			ExitReason:     channels.ReasonDone,
+			ChannelsWarned: []string{"w1", "w2"},
+		}
+		for i := 0; i < reportItemsPerPost+1; i++ {
+			results.ChannelsArchived = append(results.ChannelsArchived, fmt.Sprintf("a%d", i))
+		}
+
+		msgs := formatRunSummary("empty", results, nil)
+		require.Len(t, msgs, 4)
+		assert.Contains(t, msgs[0], "policy `empty`")
+		assert.Contains(t, msgs[0], fmt.Sprintf("Channels archived: %d", reportItemsPerPost+1))
+		assert.Contains(t, msgs[1], fmt.Sprintf("Archived channels 1 to %d of %d", reportItemsPerPost, reportItemsPerPost+1))
+		assert.Contains(t, msgs[2], fmt.Sprintf("Archived channels %d to %d of %d", reportItemsPerPost+1, reportItemsPerPost+1, reportItemsPerPost+1))
+		assert.Contains(t, msgs[3], "Warned channels 1 to 2 of 2\nw1\nw2")
+	})
+
+	t.Run("error without results", func(t *testing.T) {
+		msgs := formatRunSummary("", nil, errors.New("cannot find team 'x'"))
+		require.Len(t, msgs, 1)
+		assert.Contains(t, msgs[0], "Status: error")
+		assert.Contains(t, msgs[0], "Error: cannot find team 'x'")
+	})
+}
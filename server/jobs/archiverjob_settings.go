@@ -5,7 +5,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
 )
 
 const (
@@ -15,79 +17,215 @@ const (
 
 type ChannelArchiverJobSettings struct {
 	EnableChannelArchiver bool
+	PolicyName            string
+	IncludePublic         bool
+	IncludePrivate        bool
 	AgeInDays             int
+	EmptyOnly             bool
+	AllMembersDeactivated bool
+	MinMembers            int
+	MaxMembers            int
 	Frequency             Frequency
 	DayOfWeek             int
 	TimeOfDay             time.Time
+	IncludeChannels       []string
 	ExcludeChannels       []string
 	BatchSize             int
+	GracePeriodDays       int
+	IncludeTeams          []string       // team names or IDs
+	ExcludeTeams          []string       // team names or IDs
+	TeamAgeInDays         map[string]int // keyed by team name or ID
+	PostArchiveNotice     bool
+	NotifyOwners          bool
+	ReportChannel         string // channel ID or team-name/channel-name
+	ExportFormat          channels.ExportFormat
+	DirectChannelAction   channels.DirectChannelAction
 }
 
 func (c *ChannelArchiverJobSettings) Clone() *ChannelArchiverJobSettings {
+	include := make([]string, len(c.IncludeChannels))
+	copy(include, c.IncludeChannels)
+
 	exclude := make([]string, len(c.ExcludeChannels))
 	copy(exclude, c.ExcludeChannels)
 
+	includeTeams := make([]string, len(c.IncludeTeams))
+	copy(includeTeams, c.IncludeTeams)
+
+	excludeTeams := make([]string, len(c.ExcludeTeams))
+	copy(excludeTeams, c.ExcludeTeams)
+
+	teamAge := make(map[string]int, len(c.TeamAgeInDays))
+	for team, days := range c.TeamAgeInDays {
+		teamAge[team] = days
+	}
+
 	return &ChannelArchiverJobSettings{
 		EnableChannelArchiver: c.EnableChannelArchiver,
+		PolicyName:            c.PolicyName,
+		IncludePublic:         c.IncludePublic,
+		IncludePrivate:        c.IncludePrivate,
 		AgeInDays:             c.AgeInDays,
+		EmptyOnly:             c.EmptyOnly,
+		AllMembersDeactivated: c.AllMembersDeactivated,
+		MinMembers:            c.MinMembers,
+		MaxMembers:            c.MaxMembers,
 		Frequency:             c.Frequency,
+		DayOfWeek:             c.DayOfWeek,
 		TimeOfDay:             c.TimeOfDay,
+		IncludeChannels:       include,
 		ExcludeChannels:       exclude,
 		BatchSize:             c.BatchSize,
+		GracePeriodDays:       c.GracePeriodDays,
+		IncludeTeams:          includeTeams,
+		ExcludeTeams:          excludeTeams,
+		TeamAgeInDays:         teamAge,
+		PostArchiveNotice:     c.PostArchiveNotice,
+		NotifyOwners:          c.NotifyOwners,
+		ReportChannel:         c.ReportChannel,
+		ExportFormat:          c.ExportFormat,
+		DirectChannelAction:   c.DirectChannelAction,
 	}
 }
 
 func (c *ChannelArchiverJobSettings) String() string {
-	return fmt.Sprintf("enabled=%T; ageDays=%d; freq=%s; tod=%s; batchSize=%d; excludeLen=%d",
-		c.EnableChannelArchiver, c.AgeInDays, c.Frequency, c.TimeOfDay.Format(TimeOfDayLayout), c.BatchSize, len(c.ExcludeChannels))
+	return fmt.Sprintf("policy=%s; enabled=%t; public=%t; private=%t; ageDays=%d; emptyOnly=%t; allDeactivated=%t; members=%d-%d; freq=%s; tod=%s; batchSize=%d; includeLen=%d; excludeLen=%d; graceDays=%d; includeTeams=%v; excludeTeams=%v; teamAgeDays=%v; archiveNotice=%t; notifyOwners=%t; directAction=%s; reportChannel=%s; export=%s",
+		c.PolicyName, c.EnableChannelArchiver, c.IncludePublic, c.IncludePrivate, c.AgeInDays, c.EmptyOnly, c.AllMembersDeactivated, c.MinMembers, c.MaxMembers, c.Frequency, c.TimeOfDay.Format(TimeOfDayLayout), c.BatchSize, len(c.IncludeChannels), len(c.ExcludeChannels), c.GracePeriodDays,
+		c.IncludeTeams, c.ExcludeTeams, c.TeamAgeInDays, c.PostArchiveNotice, c.NotifyOwners, c.DirectChannelAction, c.ReportChannel, c.ExportFormat)
 }
 
-func parseChannelArchiverJobSettings(cfg *config.Configuration) (*ChannelArchiverJobSettings, error) {
-	if !cfg.EnableChannelArchiver {
+func parseChannelArchiverJobSettings(enabled bool, policy *config.ArchiverPolicy) (*ChannelArchiverJobSettings, error) {
+	if !enabled {
 		return &ChannelArchiverJobSettings{
 			EnableChannelArchiver: false,
+			PolicyName:            policy.Name,
 		}, nil
 	}
 
-	if cfg.AgeInDays < config.MinAgeInDays {
+	// channels whose members are all deactivated are archived regardless of activity and
+	// without warning, so the days of inactivity and grace period are not used.
+	if !policy.AllMembersDeactivated && policy.AgeInDays < config.MinAgeInDays {
 		return nil, fmt.Errorf("`Days of inactivity` cannot be less than %d", config.MinAgeInDays)
 	}
 
-	freq, err := FreqFromString(cfg.Frequency)
+	freq, err := FreqFromString(policy.Frequency)
 	if err != nil {
 		return nil, err
 	}
 
-	dow, err := config.ParseInt(cfg.DayOfWeek, 0, 6)
+	dow, err := config.ParseInt(policy.DayOfWeek, 0, 6)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse `Day of week`: %w", err)
 	}
 
-	tod, err := time.Parse(TimeOfDayLayout, cfg.TimeOfDay)
+	tod, err := time.Parse(TimeOfDayLayout, policy.TimeOfDay)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse `Time of day`: %w", err)
 	}
 
-	nospaces := strings.ReplaceAll(cfg.ExcludeChannels, " ", ",")
-	split := strings.Split(nospaces, ",")
-	excludes := make([]string, 0)
-	for _, s := range split {
-		ch := strings.TrimSpace(s)
-		if ch != "" {
-			excludes = append(excludes, ch)
-		}
+	includes := splitList(policy.IncludeChannels)
+	if err := store.ValidateChannelPatterns(includes); err != nil {
+		return nil, fmt.Errorf("cannot parse `Include channels`: %w", err)
+	}
+
+	excludes := splitList(policy.ExcludeChannels)
+	if err := store.ValidateChannelPatterns(excludes); err != nil {
+		return nil, fmt.Errorf("cannot parse `Exclude channels`: %w", err)
+	}
+
+	directAction, err := channels.ParseDirectChannelAction(policy.DirectChannelAction)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `Direct and group messages`: %w", err)
+	}
+
+	exportFormat, err := channels.ParseExportFormat(policy.ExportFormat)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `Export format`: %w", err)
+	}
+
+	if !policy.IncludePublicChannels && !policy.IncludePrivateChannels && directAction == channels.DirectChannelActionNone {
+		return nil, fmt.Errorf("at least one of `Public channels`, `Private channels` or `Direct and group messages` must be selected")
+	}
+
+	if err := config.ValidateMemberLimits(policy.MinMembers, policy.MaxMembers); err != nil {
+		return nil, fmt.Errorf("invalid `Minimum members` or `Maximum members`: %w", err)
 	}
 
-	if cfg.BatchSize < config.MinBatchSize || cfg.BatchSize > config.MaxBatchSize {
+	if policy.BatchSize < config.MinBatchSize || policy.BatchSize > config.MaxBatchSize {
 		return nil, fmt.Errorf("`Batch size` cannot be less than %d or more than %d", config.MinBatchSize, config.MaxBatchSize)
 	}
 
+	teamAge, err := parseTeamAgeInDays(policy.TeamAgeInDays)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `Team days of inactivity`: %w", err)
+	}
+
+	minAge := policy.AgeInDays
+	for _, days := range teamAge {
+		if days < minAge {
+			minAge = days
+		}
+	}
+	if !policy.AllMembersDeactivated && (policy.GracePeriodDays < 0 || policy.GracePeriodDays >= minAge) {
+		return nil, fmt.Errorf("`Warning grace period` cannot be negative or greater than or equal to `Days of inactivity`")
+	}
+
 	return &ChannelArchiverJobSettings{
-		EnableChannelArchiver: cfg.EnableChannelArchiver,
-		AgeInDays:             cfg.AgeInDays,
+		EnableChannelArchiver: true,
+		PolicyName:            policy.Name,
+		IncludePublic:         policy.IncludePublicChannels,
+		IncludePrivate:        policy.IncludePrivateChannels,
+		AgeInDays:             policy.AgeInDays,
+		EmptyOnly:             policy.EmptyOnly,
+		AllMembersDeactivated: policy.AllMembersDeactivated,
+		MinMembers:            policy.MinMembers,
+		MaxMembers:            policy.MaxMembers,
 		Frequency:             freq,
 		DayOfWeek:             dow,
 		TimeOfDay:             tod,
+		IncludeChannels:       includes,
 		ExcludeChannels:       excludes,
+		BatchSize:             policy.BatchSize,
+		GracePeriodDays:       policy.GracePeriodDays,
+		IncludeTeams:          splitList(policy.IncludeTeams),
+		ExcludeTeams:          splitList(policy.ExcludeTeams),
+		TeamAgeInDays:         teamAge,
+		PostArchiveNotice:     policy.PostArchiveNotice,
+		NotifyOwners:          policy.NotifyOwners,
+		ReportChannel:         strings.TrimSpace(policy.ReportChannel),
+		ExportFormat:          exportFormat,
+		DirectChannelAction:   directAction,
 	}, nil
 }
+
+// splitList splits a comma and/or space separated list, dropping empty entries.
+func splitList(s string) []string {
+	nospaces := strings.ReplaceAll(s, " ", ",")
+	split := strings.Split(nospaces, ",")
+	list := make([]string, 0)
+	for _, item := range split {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseTeamAgeInDays parses a list of `team:days` pairs into a map of days keyed by team.
+func parseTeamAgeInDays(s string) (map[string]int, error) {
+	teamAge := make(map[string]int)
+	for _, item := range splitList(s) {
+		team, daysStr, ok := strings.Cut(item, ":")
+		if !ok || team == "" {
+			return nil, fmt.Errorf("'%s' is not in the form `team:days`", item)
+		}
+
+		days, err := config.ParseInt(daysStr, config.MinAgeInDays, config.MaxAgeInDays)
+		if err != nil {
+			return nil, fmt.Errorf("invalid days for team '%s': %w", team, err)
+		}
+		teamAge[team] = days
+	}
+	return teamAge, nil
+}
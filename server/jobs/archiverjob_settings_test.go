@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+func TestParseChannelArchiverJobSettings(t *testing.T) {
+	validConfig := func() *config.Configuration {
+		cfg := config.NewConfiguration()
+		cfg.EnableChannelArchiver = true
+		cfg.Frequency = "weekly"
+		cfg.DayOfWeek = "1"
+		cfg.TimeOfDay = "1:00am -0700"
+		return cfg
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		settings, err := parseChannelArchiverJobSettings(false, &config.NewConfiguration().ArchiverPolicy)
+		require.NoError(t, err)
+		assert.False(t, settings.EnableChannelArchiver)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		settings, err := parseChannelArchiverJobSettings(true, &validConfig().ArchiverPolicy)
+		require.NoError(t, err)
+		assert.True(t, settings.EnableChannelArchiver)
+		assert.Equal(t, config.DefaultAgeInDays, settings.AgeInDays)
+		assert.Equal(t, config.DefaultArchiveBatchSize, settings.BatchSize)
+		assert.Equal(t, config.DefaultGracePeriodDays, settings.GracePeriodDays)
+		assert.Equal(t, Weekly, settings.Frequency)
+		assert.Equal(t, 1, settings.DayOfWeek)
+		assert.Empty(t, settings.ExcludeChannels)
+		assert.Empty(t, settings.TeamAgeInDays)
+		assert.Empty(t, settings.PolicyName)
+		assert.False(t, settings.EmptyOnly)
+		assert.True(t, settings.PostArchiveNotice)
+	})
+
+	t.Run("named policy", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.ArchiverPolicies = `[{"Name": "empty", "AgeInDays": 30, "EmptyOnly": true, "GracePeriodDays": 0, "PostArchiveNotice": false}]`
+		policies, err := cfg.GetArchiverPolicies()
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+
+		settings, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &policies[0])
+		require.NoError(t, err)
+		assert.Equal(t, "empty", settings.PolicyName)
+		assert.Equal(t, 30, settings.AgeInDays)
+		assert.True(t, settings.EmptyOnly)
+		assert.Equal(t, 0, settings.GracePeriodDays)
+		assert.False(t, settings.PostArchiveNotice)
+		assert.Equal(t, Weekly, settings.Frequency)
+	})
+
+	t.Run("teams", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.IncludeTeams = "engineering, legal"
+		cfg.ExcludeTeams = "legal"
+		cfg.TeamAgeInDays = "engineering:90,sales:60"
+
+		settings, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"engineering", "legal"}, settings.IncludeTeams)
+		assert.Equal(t, []string{"legal"}, settings.ExcludeTeams)
+		assert.Equal(t, map[string]int{"engineering": 90, "sales": 60}, settings.TeamAgeInDays)
+
+		clone := settings.Clone()
+		assert.Equal(t, settings, clone)
+	})
+
+	t.Run("invalid team age", func(t *testing.T) {
+		for _, s := range []string{"engineering", ":90", "engineering:abc", "engineering:10"} {
+			cfg := validConfig()
+			cfg.TeamAgeInDays = s
+			_, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+			assert.Error(t, err, s)
+		}
+	})
+
+	t.Run("channel patterns", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.IncludeChannels = "tmp-*,standup-*"
+		cfg.ExcludeChannels = "town-square, incident-*, re:^legal-"
+		settings, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tmp-*", "standup-*"}, settings.IncludeChannels)
+		assert.Equal(t, []string{"town-square", "incident-*", "re:^legal-"}, settings.ExcludeChannels)
+
+		cfg.ExcludeChannels = "re:^legal-("
+		_, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		assert.Error(t, err)
+
+		cfg.ExcludeChannels = ""
+		cfg.IncludeChannels = "re:^tmp-["
+		_, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		assert.Error(t, err)
+	})
+
+	t.Run("member limits", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.MinMembers = 2
+		cfg.MaxMembers = 500
+		settings, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+		assert.Equal(t, 2, settings.MinMembers)
+		assert.Equal(t, 500, settings.MaxMembers)
+
+		cfg.MaxMembers = 1
+		_, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		assert.Error(t, err)
+
+		cfg.MinMembers = -1
+		cfg.MaxMembers = 0
+		_, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		assert.Error(t, err)
+	})
+
+	t.Run("direct channel action", func(t *testing.T) {
+		cfg := validConfig()
+		settings, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+		assert.Equal(t, channels.DirectChannelActionNone, settings.DirectChannelAction)
+
+		cfg.DirectChannelAction = "purge"
+		settings, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+		assert.Equal(t, channels.DirectChannelActionPurge, settings.DirectChannelAction)
+
+		cfg.DirectChannelAction = "delete"
+		_, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		assert.Error(t, err)
+	})
+
+	t.Run("channel types", func(t *testing.T) {
+		settings, err := parseChannelArchiverJobSettings(true, &validConfig().ArchiverPolicy)
+		require.NoError(t, err)
+		assert.True(t, settings.IncludePublic)
+		assert.True(t, settings.IncludePrivate)
+
+		cfg := validConfig()
+		cfg.IncludePrivateChannels = false
+		settings, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+		assert.True(t, settings.IncludePublic)
+		assert.False(t, settings.IncludePrivate)
+
+		cfg.IncludePublicChannels = false
+		_, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		assert.Error(t, err)
+
+		cfg.DirectChannelAction = "hide"
+		_, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+	})
+
+	t.Run("grace period must be less than team age", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.GracePeriodDays = 45
+		_, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+
+		cfg.TeamAgeInDays = "engineering:30"
+		_, err = parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		assert.Error(t, err)
+	})
+
+	t.Run("days of inactivity not used when all members deactivated", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.AgeInDays = 0
+		_, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		assert.Error(t, err)
+
+		cfg.AllMembersDeactivated = true
+		settings, err := parseChannelArchiverJobSettings(cfg.EnableChannelArchiver, &cfg.ArchiverPolicy)
+		require.NoError(t, err)
+		assert.True(t, settings.AllMembersDeactivated)
+	})
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-api/cluster"
+)
+
+const (
+	// cronKeyPrefix is the prefix cluster.Schedule adds to job IDs for the KV key holding the
+	// job's metadata.
+	cronKeyPrefix = "cron_"
+)
+
+// ChannelArchiverJobStatus describes the state of a scheduled Channel Archiver job.
+type ChannelArchiverJobStatus struct {
+	Settings     *ChannelArchiverJobSettings
+	LastFinished time.Time // zero if the job has never finished
+	NextRun      time.Time // zero if the job is disabled
+	Running      bool      // true if a run is in progress on this server
+}
+
+// Status returns the current state of the job.
+func (j *ChannelArchiverJob) Status() (*ChannelArchiverJobStatus, error) {
+	j.mux.Lock()
+	settings := j.settings.Clone()
+	running := j.runner != nil
+	j.mux.Unlock()
+
+	var metadata cluster.JobMetadata
+	if err := j.client.KV.Get(cronKeyPrefix+j.id, &metadata); err != nil {
+		return nil, fmt.Errorf("cannot read Channel Archiver job metadata: %w", err)
+	}
+
+	status := &ChannelArchiverJobStatus{
+		Settings:     settings,
+		LastFinished: metadata.LastFinished,
+		Running:      running,
+	}
+	if settings.EnableChannelArchiver {
+		status.NextRun = nextRun(settings, metadata.LastFinished, time.Now())
+	}
+	return status, nil
+}
+
+// nextRun returns when the job should next run. A job that has never finished is scheduled
+// relative to now.
+func nextRun(settings *ChannelArchiverJobSettings, lastFinished time.Time, now time.Time) time.Time {
+	if lastFinished.IsZero() {
+		lastFinished = now
+	}
+	return settings.Frequency.CalcNext(lastFinished, settings.DayOfWeek, settings.TimeOfDay)
+}
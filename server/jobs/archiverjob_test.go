@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindChannelArchiverJob(t *testing.T) {
+	newJobManager := func(t *testing.T, policyNames ...string) *JobManager {
+		jm := NewJobManager(nil)
+		for _, name := range policyNames {
+			job, err := NewChannelArchiverJob(name, nil, nil, nil, nil)
+			require.NoError(t, err)
+			require.NoError(t, jm.AddJob(job))
+		}
+		return jm
+	}
+
+	t.Run("default job", func(t *testing.T) {
+		job, err := FindChannelArchiverJob(newJobManager(t, ""), "")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultChannelArchiverJobID, job.GetID())
+	})
+
+	t.Run("named policy", func(t *testing.T) {
+		job, err := FindChannelArchiverJob(newJobManager(t, "tmp", "legal"), "legal")
+		require.NoError(t, err)
+		assert.Equal(t, ChannelArchiverJobID("legal"), job.GetID())
+	})
+
+	t.Run("only policy", func(t *testing.T) {
+		job, err := FindChannelArchiverJob(newJobManager(t, "tmp"), "")
+		require.NoError(t, err)
+		assert.Equal(t, ChannelArchiverJobID("tmp"), job.GetID())
+	})
+
+	t.Run("policy named default", func(t *testing.T) {
+		job, err := FindChannelArchiverJob(newJobManager(t, "tmp", "default"), "")
+		require.NoError(t, err)
+		assert.Equal(t, ChannelArchiverJobID("default"), job.GetID())
+	})
+
+	t.Run("ambiguous", func(t *testing.T) {
+		_, err := FindChannelArchiverJob(newJobManager(t, "tmp", "legal"), "")
+		var notFound *PolicyNotFoundError
+		require.ErrorAs(t, err, &notFound)
+		assert.EqualError(t, err, "no default archiver policy; the policies are: legal, tmp")
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		_, err := FindChannelArchiverJob(newJobManager(t, "tmp", "legal"), "missing")
+		assert.EqualError(t, err, "no archiver policy named 'missing'; the policies are: legal, tmp")
+	})
+}
@@ -36,6 +36,25 @@ func (jm *JobManager) AddJob(job Job) error {
 	return nil
 }
 
+// GetJob returns the job with the specified ID, if any.
+func (jm *JobManager) GetJob(jobID string) (Job, bool) {
+	jobAny, ok := jm.jobs.Load(jobID)
+	if !ok {
+		return nil, false
+	}
+	return jobAny.(Job), true
+}
+
+// GetJobs returns all jobs currently managed.
+func (jm *JobManager) GetJobs() []Job {
+	jobs := make([]Job, 0)
+	jm.jobs.Range(func(k, v any) bool {
+		jobs = append(jobs, v.(Job))
+		return true
+	})
+	return jobs
+}
+
 func (jm *JobManager) RemoveJob(jobID string, timeout time.Duration) error {
 	jobAny, loaded := jm.jobs.LoadAndDelete(jobID)
 	if !loaded {
@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wiggin77/merror"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-plugin-api/cluster"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"github.com/mattermost/mattermost-server/v6/plugin"
+)
+
+const (
+	UserCleanupJobID = "user_cleanup_job"
+)
+
+// UserRemover removes a user from all of their teams and channels, and returns the number of
+// memberships removed. The plugin satisfies this interface.
+type UserRemover interface {
+	RemoveUserFromAllTeamsAndChannels(user *model.User, requesterID string) (int, error)
+}
+
+// UserCleanupJob periodically removes deactivated users from all of their teams and channels.
+type UserCleanupJob struct {
+	mux      sync.Mutex
+	settings *UserCleanupJobSettings
+	job      *cluster.Job
+	runner   *runInstance
+
+	papi     plugin.API
+	client   *pluginapi.Client
+	sqlstore *store.SQLStore
+	bot      *bot.Bot
+	remover  UserRemover
+}
+
+func NewUserCleanupJob(api plugin.API, client *pluginapi.Client, sqlstore *store.SQLStore, bot *bot.Bot, remover UserRemover) (*UserCleanupJob, error) {
+	return &UserCleanupJob{
+		settings: &UserCleanupJobSettings{},
+		papi:     api,
+		client:   client,
+		sqlstore: sqlstore,
+		bot:      bot,
+		remover:  remover,
+	}, nil
+}
+
+func (j *UserCleanupJob) GetID() string {
+	return UserCleanupJobID
+}
+
+// OnConfigurationChange is called by the job manager whenenver the plugin settings have changed.
+// Stop current job (if any) and start a new job (if enabled) with new settings.
+func (j *UserCleanupJob) OnConfigurationChange(cfg *config.Configuration) error {
+	settings, err := parseUserCleanupJobSettings(cfg)
+	if err != nil {
+		return err
+	}
+
+	// stop existing job (if any)
+	if err := j.Stop(time.Second * 10); err != nil {
+		j.client.Log.Error("Error stopping User Clean-up job for config change", "err", err)
+	}
+
+	if settings.EnableUserCleanup {
+		return j.start(settings)
+	}
+
+	return nil
+}
+
+// start schedules a new job with specified settings.
+func (j *UserCleanupJob) start(settings *UserCleanupJobSettings) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	j.settings = settings
+
+	job, err := cluster.Schedule(j.papi, UserCleanupJobID, j.nextWaitInterval, j.run)
+	if err != nil {
+		return fmt.Errorf("cannot start User Clean-up: %w", err)
+	}
+	j.job = job
+
+	j.client.Log.Debug("User Clean-up started", "settings", settings.String())
+
+	return nil
+}
+
+// Stop stops the current job (if any). If the timeout is exceeded an error
+// is returned.
+func (j *UserCleanupJob) Stop(timeout time.Duration) error {
+	var job *cluster.Job
+	var runner *runInstance
+
+	j.mux.Lock()
+	job = j.job
+	runner = j.runner
+	j.job = nil
+	j.runner = nil
+	j.mux.Unlock()
+
+	merr := merror.New()
+
+	if job != nil {
+		if err := job.Close(); err != nil {
+			merr.Append(fmt.Errorf("error closing job: %w", err))
+		}
+	}
+
+	if runner != nil {
+		if err := runner.stop(timeout); err != nil {
+			merr.Append(fmt.Errorf("error stopping job runner: %w", err))
+		}
+	}
+
+	j.client.Log.Debug("User Clean-up stopped", "err", merr.ErrorOrNil())
+
+	return merr.ErrorOrNil()
+}
+
+func (j *UserCleanupJob) getSettings() *UserCleanupJobSettings {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.settings.Clone()
+}
+
+// nextWaitInterval is called by the cluster job scheduler to determine how long to wait until the
+// next job run.
+func (j *UserCleanupJob) nextWaitInterval(now time.Time, metaData cluster.JobMetadata) time.Duration {
+	settings := j.getSettings()
+
+	lastFinished := metaData.LastFinished
+	if lastFinished.IsZero() {
+		lastFinished = now
+	}
+
+	next := settings.Frequency.CalcNext(lastFinished, settings.DayOfWeek, settings.TimeOfDay)
+	delta := next.Sub(now)
+
+	j.client.Log.Debug("User Clean-up next run scheduled", "last", lastFinished.Format(FullLayout), "next", next.Format(FullLayout), "wait", delta.String())
+
+	return delta
+}
+
+func (j *UserCleanupJob) run() {
+	exitSignal := make(chan struct{})
+	ctx, canceller := context.WithCancel(context.Background())
+
+	runner := &runInstance{
+		canceller:  canceller,
+		exitSignal: exitSignal,
+	}
+
+	var oldRunner *runInstance
+	var settings *UserCleanupJobSettings
+	j.mux.Lock()
+	oldRunner = j.runner
+	j.runner = runner
+	settings = j.settings.Clone()
+	j.mux.Unlock()
+
+	defer func() {
+		close(exitSignal)
+		j.mux.Lock()
+		j.runner = nil
+		j.mux.Unlock()
+	}()
+
+	if oldRunner != nil {
+		j.client.Log.Error("Multiple User Clean-up jobs scheduled concurrently; there can be only one")
+		return
+	}
+
+	start := time.Now()
+	removed, failed, err := j.removeDeactivatedUsers(ctx, settings, start)
+	if err != nil {
+		j.client.Log.Error("Error running User Clean-up job", "users_removed", removed, "users_failed", failed, "err", err)
+		return
+	}
+
+	j.client.Log.Info("User Clean-up job", "users_removed", removed, "users_failed", failed, "canceled", ctx.Err() != nil, "duration", time.Since(start).String())
+}
+
+// removeDeactivatedUsers removes users deactivated more than the configured number of days ago
+// from all of their teams and channels, a batch at a time. Users that cannot be removed are
+// logged and skipped.
+func (j *UserCleanupJob) removeDeactivatedUsers(ctx context.Context, settings *UserCleanupJobSettings, now time.Time) (removed int, failed int, err error) {
+	deactivatedBefore := model.GetMillisForTime(now.AddDate(0, 0, -settings.DeactivatedDays))
+
+	var afterID string
+	for ctx.Err() == nil {
+		userIDs, err := j.sqlstore.GetDeactivatedUsersWithMemberships(deactivatedBefore, afterID, settings.BatchSize)
+		if err != nil {
+			return removed, failed, fmt.Errorf("cannot fetch deactivated users: %w", err)
+		}
+		if len(userIDs) == 0 {
+			break
+		}
+
+		for _, userID := range userIDs {
+			if ctx.Err() != nil {
+				break
+			}
+			var memberships int
+			user, err := j.client.User.Get(userID)
+			if err == nil {
+				memberships, err = j.remover.RemoveUserFromAllTeamsAndChannels(user, j.bot.ID())
+			}
+			if err != nil {
+				j.client.Log.Warn("User Clean-up cannot remove user from teams and channels", "user_id", userID, "err", err)
+				failed++
+				continue
+			}
+			if memberships > 0 {
+				removed++
+			}
+		}
+		afterID = userIDs[len(userIDs)-1]
+	}
+	return removed, failed, nil
+}
@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+type UserCleanupJobSettings struct {
+	EnableUserCleanup bool
+	DeactivatedDays   int
+	Frequency         Frequency
+	DayOfWeek         int
+	TimeOfDay         time.Time
+	BatchSize         int
+}
+
+func (c *UserCleanupJobSettings) Clone() *UserCleanupJobSettings {
+	clone := *c
+	return &clone
+}
+
+func (c *UserCleanupJobSettings) String() string {
+	return fmt.Sprintf("enabled=%t; deactivatedDays=%d; freq=%s; dow=%d; tod=%s; batchSize=%d",
+		c.EnableUserCleanup, c.DeactivatedDays, c.Frequency, c.DayOfWeek, c.TimeOfDay.Format(TimeOfDayLayout), c.BatchSize)
+}
+
+func parseUserCleanupJobSettings(cfg *config.Configuration) (*UserCleanupJobSettings, error) {
+	if !cfg.EnableUserCleanup {
+		return &UserCleanupJobSettings{EnableUserCleanup: false}, nil
+	}
+
+	if cfg.UserCleanupDays < config.MinUserCleanupDays || cfg.UserCleanupDays > config.MaxUserCleanupDays {
+		return nil, fmt.Errorf("`Days since deactivation` cannot be less than %d or more than %d", config.MinUserCleanupDays, config.MaxUserCleanupDays)
+	}
+
+	freq, err := FreqFromString(cfg.UserCleanupFrequency)
+	if err != nil {
+		return nil, err
+	}
+
+	dow, err := config.ParseInt(cfg.UserCleanupDayOfWeek, 0, 6)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `User clean-up day of week`: %w", err)
+	}
+
+	tod, err := time.Parse(TimeOfDayLayout, cfg.UserCleanupTimeOfDay)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `User clean-up time of day`: %w", err)
+	}
+
+	if cfg.UserCleanupBatchSize < config.MinBatchSize || cfg.UserCleanupBatchSize > config.MaxBatchSize {
+		return nil, fmt.Errorf("`User clean-up batch size` cannot be less than %d or more than %d", config.MinBatchSize, config.MaxBatchSize)
+	}
+
+	return &UserCleanupJobSettings{
+		EnableUserCleanup: true,
+		DeactivatedDays:   cfg.UserCleanupDays,
+		Frequency:         freq,
+		DayOfWeek:         dow,
+		TimeOfDay:         tod,
+		BatchSize:         cfg.UserCleanupBatchSize,
+	}, nil
+}
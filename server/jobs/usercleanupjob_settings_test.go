@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+func TestParseUserCleanupJobSettings(t *testing.T) {
+	validConfig := func() *config.Configuration {
+		cfg := config.NewConfiguration()
+		cfg.EnableUserCleanup = true
+		cfg.UserCleanupFrequency = "daily"
+		cfg.UserCleanupDayOfWeek = "1"
+		cfg.UserCleanupTimeOfDay = "2:00am -0700"
+		return cfg
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		settings, err := parseUserCleanupJobSettings(config.NewConfiguration())
+		require.NoError(t, err)
+		assert.False(t, settings.EnableUserCleanup)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		settings, err := parseUserCleanupJobSettings(validConfig())
+		require.NoError(t, err)
+		assert.True(t, settings.EnableUserCleanup)
+		assert.Equal(t, config.DefaultUserCleanupDays, settings.DeactivatedDays)
+		assert.Equal(t, config.DefaultUserCleanupBatchSize, settings.BatchSize)
+		assert.Equal(t, Daily, settings.Frequency)
+		assert.Equal(t, 1, settings.DayOfWeek)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, modify := range map[string]func(cfg *config.Configuration){
+			"negative days":   func(cfg *config.Configuration) { cfg.UserCleanupDays = -1 },
+			"bad frequency":   func(cfg *config.Configuration) { cfg.UserCleanupFrequency = "hourly" },
+			"bad day of week": func(cfg *config.Configuration) { cfg.UserCleanupDayOfWeek = "7" },
+			"bad time of day": func(cfg *config.Configuration) { cfg.UserCleanupTimeOfDay = "25:00" },
+			"batch too small": func(cfg *config.Configuration) { cfg.UserCleanupBatchSize = 1 },
+		} {
+			t.Run(name, func(t *testing.T) {
+				cfg := validConfig()
+				modify(cfg)
+				_, err := parseUserCleanupJobSettings(cfg)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
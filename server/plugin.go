@@ -12,6 +12,7 @@ import (
 	"github.com/mattermost/mattermost-server/v6/model"
 	"github.com/mattermost/mattermost-server/v6/plugin"
 
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/command"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/jobs"
@@ -19,8 +20,9 @@ import (
 )
 
 const (
-	routeRemoveUserFromAllTeamsAndChannels = "/remove_user_from_all_teams_and_channels"
-	ChannelArchiverJobID                   = "channel_archiver_job"
+	routeRemoveUserFromAllTeamsAndChannels  = "/remove_user_from_all_teams_and_channels"
+	routeRemoveUsersFromAllTeamsAndChannels = "/remove_users_from_all_teams_and_channels"
+	routeRunChannelArchiver                 = "/channel_archiver/run"
 )
 
 type ErrorResponse struct {
@@ -38,18 +40,29 @@ type Plugin struct {
 
 	Client   *pluginapi.Client
 	SQLStore *store.SQLStore
+	bot      *bot.Bot
 
 	channelArchiverCmd *command.ChannelArchiverCmd
 	jobManager         *jobs.JobManager
+	removalJobs        *removalJobRunner
 }
 
 func (p *Plugin) ServeHTTP(_ *plugin.Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	switch r.URL.Path {
-	case routeRemoveUserFromAllTeamsAndChannels:
+	switch path := r.URL.Path; {
+	case path == routeRemoveUserFromAllTeamsAndChannels:
 		p.handleRemoveUserFromAllTeamsAndChannels(w, r)
 		return
+	case path == routeRemoveUsersFromAllTeamsAndChannels:
+		p.handleRemoveUsersFromAllTeamsAndChannels(w, r)
+		return
+	case strings.HasPrefix(path, routeRemovalJobs):
+		p.handleGetRemovalJob(w, r)
+		return
+	case path == routeRunChannelArchiver:
+		p.handleRunChannelArchiver(w, r)
+		return
 	default:
 		w.WriteHeader(http.StatusNotFound)
 		_ = json.NewEncoder(w).Encode(ErrorResponse{
@@ -66,30 +79,91 @@ func (p *Plugin) OnActivate() error {
 		return err
 	}
 	p.SQLStore = SQLStore
+	p.removalJobs = newRemovalJobRunner()
 
-	// Register slash command for channel archiver
-	p.channelArchiverCmd, err = command.RegisterChannelArchiver(p.Client, p.SQLStore)
+	p.bot, err = bot.New(p.Client)
 	if err != nil {
-		return fmt.Errorf("cannot register channel archiver slash command: %w", err)
+		return fmt.Errorf("cannot create bot: %w", err)
 	}
 
 	// Create job manager
 	p.jobManager = jobs.NewJobManager(&p.Client.Log)
 
-	// Create job for channel archiver
-	channelArchiverJob, err := jobs.NewChannelArchiverJob(ChannelArchiverJobID, p.API, p.Client, SQLStore)
+	// Register slash command for channel archiver
+	p.channelArchiverCmd, err = command.RegisterChannelArchiver(p.Client, p.SQLStore, p.bot, p.API, p.jobManager, p.getConfiguration)
 	if err != nil {
-		return fmt.Errorf("cannot create channel archiver job: %w", err)
+		return fmt.Errorf("cannot register channel archiver slash command: %w", err)
 	}
-	if err := p.jobManager.AddJob(channelArchiverJob); err != nil {
-		return fmt.Errorf("cannot add channel archiver job: %w", err)
+
+	// Create a job for each channel archiver policy
+	if err := p.syncChannelArchiverJobs(p.getConfiguration()); err != nil {
+		p.Client.Log.Error("cannot create channel archiver jobs", "err", err)
+	}
+
+	// Create job for deactivated user clean-up
+	userCleanupJob, err := jobs.NewUserCleanupJob(p.API, p.Client, p.SQLStore, p.bot, p)
+	if err != nil {
+		return fmt.Errorf("cannot create user clean-up job: %w", err)
+	}
+	if err := p.jobManager.AddJob(userCleanupJob); err != nil {
+		return fmt.Errorf("cannot add user clean-up job: %w", err)
 	}
 	_ = p.jobManager.OnConfigurationChange(p.getConfiguration())
 
 	return nil
 }
 
+// syncChannelArchiverJobs adds a job for each configured channel archiver policy and removes
+// the jobs of policies that are no longer configured.
+func (p *Plugin) syncChannelArchiverJobs(cfg *config.Configuration) error {
+	policies, err := cfg.GetArchiverPolicies()
+	if err != nil {
+		return err
+	}
+
+	policyNames := make(map[string]string) // job ID -> policy name
+	for _, policy := range policies {
+		policyNames[jobs.ChannelArchiverJobID(policy.Name)] = policy.Name
+	}
+
+	for _, job := range p.jobManager.GetJobs() {
+		if _, ok := job.(*jobs.ChannelArchiverJob); !ok {
+			continue
+		}
+		if _, ok := policyNames[job.GetID()]; ok {
+			continue
+		}
+		if err := p.jobManager.RemoveJob(job.GetID(), time.Second*15); err != nil {
+			return fmt.Errorf("cannot remove channel archiver job %s: %w", job.GetID(), err)
+		}
+	}
+
+	for jobID, policyName := range policyNames {
+		if _, ok := p.jobManager.GetJob(jobID); ok {
+			continue
+		}
+		job, err := jobs.NewChannelArchiverJob(policyName, p.API, p.Client, p.SQLStore, p.bot)
+		if err != nil {
+			return fmt.Errorf("cannot create channel archiver job %s: %w", jobID, err)
+		}
+		if err := p.jobManager.AddJob(job); err != nil {
+			return fmt.Errorf("cannot add channel archiver job %s: %w", jobID, err)
+		}
+	}
+	return nil
+}
+
 func (p *Plugin) OnDeactivate() error {
+	if p.channelArchiverCmd != nil {
+		if err := p.channelArchiverCmd.Close(time.Second * 15); err != nil {
+			p.Client.Log.Error("error stopping channel archiver runs", "err", err)
+		}
+	}
+	if p.removalJobs != nil {
+		if err := p.removalJobs.close(time.Second * 15); err != nil {
+			p.Client.Log.Error("error stopping removal jobs", "err", err)
+		}
+	}
 	if p.jobManager != nil {
 		if err := p.jobManager.Close(time.Second * 15); err != nil {
 			return fmt.Errorf("error closing job manager: %w", err)
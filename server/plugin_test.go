@@ -2,16 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
 	"github.com/mattermost/mattermost-server/v6/model"
 	"github.com/mattermost/mattermost-server/v6/plugin/plugintest"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/jobs"
 )
 
 const deleteChannelMembersRoute = "/remove_user_from_all_teams_and_channels"
@@ -116,11 +125,12 @@ func TestServeHTTP(t *testing.T) {
 				}, nil)
 
 				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{}, nil)
+				api.On("GetChannelMembersForUser", "", "deactivated_user_id", 0, 1000).Return([]*model.ChannelMember{}, nil)
 
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 				return r
 			},
-			expectedStatus: 200,
+			expectedStatus: 202,
 			expectedError:  "",
 		},
 		"username provided in request": {
@@ -143,11 +153,12 @@ func TestServeHTTP(t *testing.T) {
 				}, nil)
 
 				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{}, nil)
+				api.On("GetChannelMembersForUser", "", "deactivated_user_id", 0, 1000).Return([]*model.ChannelMember{}, nil)
 
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 				return r
 			},
-			expectedStatus: 200,
+			expectedStatus: 202,
 			expectedError:  "",
 		},
 	} {
@@ -155,11 +166,13 @@ func TestServeHTTP(t *testing.T) {
 			p := &Plugin{}
 			api := &plugintest.API{}
 			p.SetAPI(api)
+			setupRemovalJobs(p, api)
 
 			w := httptest.NewRecorder()
 			r := tc.makeRequest(api)
 
 			p.ServeHTTP(nil, w, r)
+			p.removalJobs.wg.Wait()
 
 			result := w.Result()
 			require.NotNil(t, result)
@@ -184,9 +197,8 @@ func TestServeHTTP(t *testing.T) {
 
 func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 	for name, tc := range map[string]struct {
-		runAssertions  func(api *plugintest.API)
-		expectedStatus int
-		expectedError  string
+		runAssertions    func(api *plugintest.API)
+		expectedJobError string
 	}{
 		"happy path, user is member of one team": {
 			runAssertions: func(api *plugintest.API) {
@@ -195,7 +207,7 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 					UserId: "deactivated_user_id",
 				}}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
+				api.On("GetChannelMembersForUser", "teamid1", "deactivated_user_id", 0, 1000).Return([]*model.ChannelMember{
 					{
 						ChannelId: "channelid1",
 						UserId:    "deactivated_user_id",
@@ -219,8 +231,6 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 				api.On("LogDebug", "Removed user from all channels in team.", "username", "deactivated_username", "team", "teamid1")
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 			},
-			expectedStatus: 200,
-			expectedError:  "",
 		},
 		"happy path, user is member of two teams": {
 			runAssertions: func(api *plugintest.API) {
@@ -234,7 +244,7 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
+				api.On("GetChannelMembersForUser", "teamid1", "deactivated_user_id", 0, 1000).Return([]*model.ChannelMember{
 					{
 						ChannelId: "channelid1",
 						UserId:    "deactivated_user_id",
@@ -249,7 +259,7 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid2", 0, 1000).Return([]*model.ChannelMember{
+				api.On("GetChannelMembersForUser", "teamid2", "deactivated_user_id", 0, 1000).Return([]*model.ChannelMember{
 					{
 						ChannelId: "channelid4",
 						UserId:    "deactivated_user_id",
@@ -278,8 +288,6 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 				api.On("LogDebug", "Removed user from all channels in team.", "username", "deactivated_username", "team", "teamid2")
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 			},
-			expectedStatus: 200,
-			expectedError:  "",
 		},
 		"error deleting team member": {
 			runAssertions: func(api *plugintest.API) {
@@ -290,7 +298,7 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
+				api.On("GetChannelMembersForUser", "teamid1", "deactivated_user_id", 0, 1000).Return([]*model.ChannelMember{
 					{
 						ChannelId: "channelid1",
 						UserId:    "deactivated_user_id",
@@ -311,10 +319,9 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 
 				api.On("DeleteTeamMember", "teamid1", "deactivated_user_id", "requesting_user_id").Return(&model.AppError{DetailedError: "some database error"})
 
-				api.On("LogError", "error processing request: failed to process team member. user=deactivated_username team=teamid1: failed to remove user from team: : , some database error")
+				api.On("LogError", "Error removing user from all teams and channels", "user", "deactivated_username", "err", "failed to process team member. user=deactivated_username team=teamid1: failed to remove user from team: : , some database error")
 			},
-			expectedStatus: 500,
-			expectedError:  "error processing request: failed to process team member. user=deactivated_username team=teamid1: failed to remove user from team: : , some database error",
+			expectedJobError: "failed to process team member. user=deactivated_username team=teamid1: failed to remove user from team: : , some database error",
 		},
 		"error deleting channel member": {
 			runAssertions: func(api *plugintest.API) {
@@ -325,7 +332,7 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
+				api.On("GetChannelMembersForUser", "teamid1", "deactivated_user_id", 0, 1000).Return([]*model.ChannelMember{
 					{
 						ChannelId: "channelid1",
 						UserId:    "deactivated_user_id",
@@ -346,10 +353,9 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 
 				api.On("GetChannel", "channelid3").Return(&model.Channel{Name: "channelname3"}, nil)
 
-				api.On("LogError", "error processing request: failed to process team member. user=deactivated_username team=teamid1: failed to process channel member. channel=channelid3: failed to remove user from channel: : , some database error")
+				api.On("LogError", "Error removing user from all teams and channels", "user", "deactivated_username", "err", "failed to process team member. user=deactivated_username team=teamid1: failed to process channel member. channel=channelid3: failed to remove user from channel: : , some database error")
 			},
-			expectedStatus: 500,
-			expectedError:  "error processing request: failed to process team member. user=deactivated_username team=teamid1: failed to process channel member. channel=channelid3: failed to remove user from channel: : , some database error",
+			expectedJobError: "failed to process team member. user=deactivated_username team=teamid1: failed to process channel member. channel=channelid3: failed to remove user from channel: : , some database error",
 		},
 		"handle town square case": {
 			runAssertions: func(api *plugintest.API) {
@@ -360,7 +366,7 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
+				api.On("GetChannelMembersForUser", "teamid1", "deactivated_user_id", 0, 1000).Return([]*model.ChannelMember{
 					{
 						ChannelId: "channelid1",
 						UserId:    "deactivated_user_id",
@@ -386,14 +392,13 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 				api.On("LogDebug", "Removed user from all channels in team.", "username", "deactivated_username", "team", "teamid1")
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 			},
-			expectedStatus: 200,
-			expectedError:  "",
 		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			p := &Plugin{}
 			api := &plugintest.API{}
 			p.SetAPI(api)
+			setupRemovalJobs(p, api)
 
 			w := httptest.NewRecorder()
 
@@ -425,16 +430,548 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 			require.NoError(t, err)
 
 			require.Equal(t, result.Header.Get("Content-Type"), "application/json")
+			require.Equal(t, http.StatusAccepted, result.StatusCode)
+
+			var jobResponse RemovalJobResponse
+			err = json.Unmarshal(bodyBytes, &jobResponse)
+			require.NoError(t, err)
+
+			p.removalJobs.wg.Wait()
+			job, err := p.getRemovalJob(jobResponse.JobID)
+			require.NoError(t, err)
+			require.NotNil(t, job)
+			require.Equal(t, RemovalJobStatusCompleted, job.Status)
+			require.Equal(t, 1, job.UsersProcessed)
+			require.Len(t, job.Results, 1)
+			require.Equal(t, "deactivated_user_id", job.Results[0].UserID)
+			if tc.expectedJobError != "" {
+				require.Equal(t, 1, job.Failed)
+				require.Equal(t, tc.expectedJobError, job.Results[0].Error)
+			} else {
+				require.Equal(t, 1, job.Succeeded)
+				require.True(t, job.Results[0].Success)
+			}
+		})
+	}
+}
+
+func TestHandleRemoveUserFromAllTeamsAndChannelsDryRun(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+	setupRemovalJobs(p, api)
+
+	api.On("GetUser", "requesting_user_id").Return(&model.User{
+		Roles: "system_user system_admin",
+	}, nil)
+	api.On("GetUser", "deactivated_user_id").Return(&model.User{
+		Id:       "deactivated_user_id",
+		Username: "deactivated_username",
+	}, nil)
+
+	api.On("GetTeamsForUser", "deactivated_user_id").Return([]*model.Team{
+		{Id: "teamid1", Name: "team1"},
+		{Id: "teamid2", Name: "team2"},
+	}, nil)
+	// The user has left team 3 but is still in one of its channels.
+	api.On("GetTeam", "teamid3").Return(&model.Team{Id: "teamid3", Name: "team3"}, nil)
+
+	api.On("GetChannelsForTeamForUser", "", "deactivated_user_id", true).Return([]*model.Channel{
+		{Id: "channelid1", TeamId: "teamid1", Name: "town-square", Type: model.ChannelTypeOpen},
+		{Id: "channelid2", TeamId: "teamid2", Name: "channel2", Type: model.ChannelTypePrivate},
+		{Id: "channelid3", TeamId: "teamid1", Name: "channel3", Type: model.ChannelTypeOpen, DeleteAt: 1},
+		{Id: "channelid4", TeamId: "teamid3", Name: "channel4", Type: model.ChannelTypeOpen},
+		{Id: "dm", Name: "deactivated_user_id__other_user_id", Type: model.ChannelTypeDirect},
+	}, nil)
+
+	b, _ := json.Marshal(Payload{UserID: "deactivated_user_id", DryRun: true})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, deleteChannelMembersRoute, bytes.NewReader(b))
+	r.Header.Set("Mattermost-User-Id", "requesting_user_id")
+
+	p.ServeHTTP(nil, w, r)
+
+	result := w.Result()
+	require.NotNil(t, result)
+	defer result.Body.Close()
+	bodyBytes, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusAccepted, result.StatusCode)
+
+	var jobResponse RemovalJobResponse
+	require.NoError(t, json.Unmarshal(bodyBytes, &jobResponse))
+
+	p.removalJobs.wg.Wait()
+	job, err := p.getRemovalJob(jobResponse.JobID)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	require.True(t, job.DryRun)
+	require.Equal(t, RemovalJobStatusCompleted, job.Status)
+	require.Equal(t, 1, job.Succeeded)
+	require.Len(t, job.Results, 1)
+
+	plan := job.Results[0].Plan
+	require.NotNil(t, plan)
+	require.Equal(t, "deactivated_username", plan.Username)
+	require.Equal(t, 2, plan.TeamCount)
+	require.Equal(t, 2, plan.ChannelCount)
+	require.Equal(t, 3, plan.SkippedChannelCount)
+	require.Equal(t, []TeamRemovalPlan{
+		{TeamID: "teamid1", TeamName: "team1", Member: true, Channels: []ChannelRemovalPlan{
+			{ChannelID: "channelid1", Name: "town-square", Type: model.ChannelTypeOpen, Skipped: true, SkipReason: "default channel"},
+			{ChannelID: "channelid3", Name: "channel3", Type: model.ChannelTypeOpen, Skipped: true, SkipReason: "archived channel"},
+		}},
+		{TeamID: "teamid2", TeamName: "team2", Member: true, Channels: []ChannelRemovalPlan{
+			{ChannelID: "channelid2", Name: "channel2", Type: model.ChannelTypePrivate},
+		}},
+		{TeamID: "teamid3", TeamName: "team3", Channels: []ChannelRemovalPlan{
+			{ChannelID: "channelid4", Name: "channel4", Type: model.ChannelTypeOpen},
+		}},
+	}, plan.Teams)
+	require.Equal(t, []ChannelRemovalPlan{
+		{ChannelID: "dm", Name: "deactivated_user_id__other_user_id", Type: model.ChannelTypeDirect, Skipped: true, SkipReason: "direct or group message"},
+	}, plan.DirectChannels)
+
+	api.AssertNotCalled(t, "DeleteChannelMember", mock.Anything, mock.Anything)
+	api.AssertNotCalled(t, "DeleteTeamMember", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRemoveUserPagesThroughMemberships(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+
+	user := &model.User{Id: "deactivated_user_id", Username: "deactivated_username"}
+
+	// A full first page of teams, then one more on the second page.
+	var teamPage []*model.TeamMember
+	for i := 0; i < membershipPageSize; i++ {
+		teamPage = append(teamPage, &model.TeamMember{TeamId: fmt.Sprintf("team%d", i), UserId: user.Id})
+	}
+	api.On("GetTeamMembersForUser", user.Id, 0, membershipPageSize).Return(teamPage, nil)
+	api.On("GetTeamMembersForUser", user.Id, 1, membershipPageSize).Return([]*model.TeamMember{{TeamId: "lastteam", UserId: user.Id}}, nil)
+
+	// The first team has a full page of channels and one more, including a direct message
+	// that every team returns.
+	var channelPage []*model.ChannelMember
+	for i := 0; i < membershipPageSize-1; i++ {
+		channelPage = append(channelPage, &model.ChannelMember{ChannelId: fmt.Sprintf("channel%d", i), UserId: user.Id})
+	}
+	channelPage = append(channelPage, &model.ChannelMember{ChannelId: "dm", UserId: user.Id})
+	api.On("GetChannelMembersForUser", "team0", user.Id, 0, membershipPageSize).Return(channelPage, nil)
+	api.On("GetChannelMembersForUser", "team0", user.Id, 1, membershipPageSize).Return([]*model.ChannelMember{{ChannelId: "extra", UserId: user.Id}}, nil)
+	api.On("GetChannelMembersForUser", mock.AnythingOfType("string"), user.Id, 0, membershipPageSize).Return([]*model.ChannelMember{{ChannelId: "dm", UserId: user.Id}}, nil)
+
+	api.On("DeleteChannelMember", mock.AnythingOfType("string"), user.Id).Return(nil)
+	api.On("DeleteTeamMember", mock.AnythingOfType("string"), user.Id, "requesting_user_id").Return(nil)
+	api.On("LogDebug", "Removed user from all channels in team.", "username", user.Username, "team", mock.AnythingOfType("string"))
+	api.On("LogDebug", "Finished for user.", "username", user.Username)
+
+	var counts removalCounts
+	err := p.removeUser(context.Background(), user, "requesting_user_id", func(c removalCounts) {
+		counts = c
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, removalCounts{
+		TeamsTotal:         membershipPageSize + 1,
+		TeamsProcessed:     membershipPageSize + 1,
+		ChannelsTotal:      membershipPageSize + 1,
+		ChannelsProcessed:  membershipPageSize + 1,
+		MembershipsRemoved: 2 * (membershipPageSize + 1),
+	}, counts)
+	api.AssertNumberOfCalls(t, "DeleteTeamMember", membershipPageSize+1)
+	api.AssertNumberOfCalls(t, "DeleteChannelMember", membershipPageSize+1)
+}
+
+func TestRemoveUserWithoutTeams(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+
+	user := &model.User{Id: "deactivated_user_id", Username: "deactivated_username"}
+
+	api.On("GetTeamMembersForUser", user.Id, 0, membershipPageSize).Return([]*model.TeamMember{}, nil)
+	api.On("GetChannelMembersForUser", "", user.Id, 0, membershipPageSize).Return([]*model.ChannelMember{
+		{ChannelId: "channelid1", UserId: user.Id},
+		{ChannelId: "dm", UserId: user.Id},
+	}, nil)
+	api.On("DeleteChannelMember", "channelid1", user.Id).Return(nil)
+	api.On("DeleteChannelMember", "dm", user.Id).Return(&model.AppError{Message: "cannot leave a direct message"})
+	api.On("GetChannel", "dm").Return(&model.Channel{Id: "dm", Type: model.ChannelTypeDirect}, nil)
+	api.On("LogDebug", "Finished for user.", "username", user.Username)
+
+	removed, err := p.RemoveUserFromAllTeamsAndChannels(user, "requesting_user_id")
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	api.AssertNotCalled(t, "DeleteTeamMember", mock.Anything, mock.Anything, mock.Anything)
+
+	// nothing left to remove
+	api.ExpectedCalls = nil
+	api.On("GetTeamMembersForUser", user.Id, 0, membershipPageSize).Return([]*model.TeamMember{}, nil)
+	api.On("GetChannelMembersForUser", "", user.Id, 0, membershipPageSize).Return([]*model.ChannelMember{{ChannelId: "dm", UserId: user.Id}}, nil)
+	api.On("DeleteChannelMember", "dm", user.Id).Return(&model.AppError{Message: "cannot leave a direct message"})
+	api.On("GetChannel", "dm").Return(&model.Channel{Id: "dm", Type: model.ChannelTypeDirect}, nil)
+	api.On("LogDebug", "Finished for user.", "username", user.Username)
+
+	removed, err = p.RemoveUserFromAllTeamsAndChannels(user, "requesting_user_id")
+	require.NoError(t, err)
+	require.Equal(t, 0, removed)
+}
+
+func TestRemoveUserTwice(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+
+	user := &model.User{Id: "deactivated_user_id", Username: "deactivated_username"}
+	dm := &model.ChannelMember{ChannelId: "dm", UserId: user.Id}
+
+	api.On("GetTeamMembersForUser", user.Id, 0, membershipPageSize).Return([]*model.TeamMember{{TeamId: "teamid1", UserId: user.Id}}, nil).Once()
+	api.On("GetChannelMembersForUser", "teamid1", user.Id, 0, membershipPageSize).Return([]*model.ChannelMember{
+		{ChannelId: "channelid1", UserId: user.Id},
+		dm,
+	}, nil)
+	api.On("DeleteChannelMember", "channelid1", user.Id).Return(nil)
+	api.On("DeleteChannelMember", "dm", user.Id).Return(&model.AppError{Message: "cannot leave a direct message"})
+	api.On("GetChannel", "dm").Return(&model.Channel{Id: "dm", Type: model.ChannelTypeDirect}, nil)
+	api.On("DeleteTeamMember", "teamid1", user.Id, "requesting_user_id").Return(nil)
+	api.On("LogDebug", "Removed user from all channels in team.", "username", user.Username, "team", "teamid1")
+	api.On("LogDebug", "Finished for user.", "username", user.Username)
+
+	removed, err := p.RemoveUserFromAllTeamsAndChannels(user, "requesting_user_id")
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	// The server still returns the team membership the user has left.
+	api.On("GetTeamMembersForUser", user.Id, 0, membershipPageSize).Return([]*model.TeamMember{{TeamId: "teamid1", UserId: user.Id, DeleteAt: 1}}, nil)
+	api.On("GetChannelMembersForUser", "", user.Id, 0, membershipPageSize).Return([]*model.ChannelMember{dm}, nil)
+
+	removed, err = p.RemoveUserFromAllTeamsAndChannels(user, "requesting_user_id")
+	require.NoError(t, err)
+	require.Equal(t, 0, removed)
+	api.AssertNumberOfCalls(t, "DeleteTeamMember", 1)
+	api.AssertNumberOfCalls(t, "DeleteChannelMember", 3)
+}
+
+func TestHandleRunChannelArchiver(t *testing.T) {
+	for name, tc := range map[string]struct {
+		method         string
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		"invalid http method": {
+			method:         http.MethodGet,
+			expectedStatus: 405,
+			expectedError:  "unexpected HTTP method GET. Should be POST",
+		},
+		"invalid payload": {
+			method:         http.MethodPost,
+			body:           "{",
+			expectedStatus: 400,
+			expectedError:  "error decoding payload: unexpected EOF",
+		},
+		"unknown policy": {
+			method:         http.MethodPost,
+			body:           `{"policy": "missing"}`,
+			expectedStatus: 404,
+			expectedError:  "no archiver policy named 'missing'; only the default policy is configured",
+		},
+		"default policy disabled": {
+			method:         http.MethodPost,
+			expectedStatus: 409,
+			expectedError:  jobs.ErrJobDisabled.Error(),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := &Plugin{}
+			api := &plugintest.API{}
+			p.SetAPI(api)
+
+			p.jobManager = jobs.NewJobManager(nil)
+			job, err := jobs.NewChannelArchiverJob("", api, nil, nil, nil)
+			require.NoError(t, err)
+			require.NoError(t, p.jobManager.AddJob(job))
+
+			api.On("GetUser", "requesting_user_id").Return(&model.User{
+				Roles: "system_user system_admin",
+			}, nil)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, "/channel_archiver/run", strings.NewReader(tc.body))
+			r.Header.Set("Mattermost-User-Id", "requesting_user_id")
+
+			p.ServeHTTP(nil, w, r)
+
+			result := w.Result()
+			require.NotNil(t, result)
+			defer result.Body.Close()
+			bodyBytes, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+
 			require.Equal(t, tc.expectedStatus, result.StatusCode)
 
 			var errResponse ErrorResponse
-			err = json.Unmarshal(bodyBytes, &errResponse)
+			require.NoError(t, json.Unmarshal(bodyBytes, &errResponse))
+			require.Equal(t, tc.expectedError, errResponse.Error)
+		})
+	}
+}
+
+func TestHandleRemoveUsersFromAllTeamsAndChannels(t *testing.T) {
+	mockUsers := func(api *plugintest.API) {
+		user := &model.User{Id: model.NewId(), Username: "alice"}
+		api.On("GetUser", user.Id).Return(user, nil)
+		api.On("GetUserByUsername", "alice").Return(user, nil)
+		api.On("GetUserByEmail", "alice@example.com").Return(user, nil)
+		api.On("GetUserByUsername", "missing").Return(nil, &model.AppError{DetailedError: "user not found"})
+		api.On("GetTeamMembersForUser", user.Id, 0, 1000).Return([]*model.TeamMember{}, nil)
+		api.On("GetChannelMembersForUser", "", user.Id, 0, 1000).Return([]*model.ChannelMember{}, nil)
+		api.On("LogDebug", "Finished for user.", "username", "alice")
+		api.On("LogError", "Error removing user from all teams and channels", "user", "missing", "err", "failed to get user with username missing: : , user not found")
+	}
+
+	for name, tc := range map[string]struct {
+		contentType        string
+		body               string
+		expectedStatus     int
+		expectedError      string
+		expectedSucceeded  int
+		expectedFailed     int
+		expectedDuplicates int
+	}{
+		"json with one failure": {
+			contentType:       "application/json",
+			body:              `{"usernames": ["alice", "missing"]}`,
+			expectedStatus:    202,
+			expectedSucceeded: 1,
+			expectedFailed:    1,
+		},
+		"json naming a user twice": {
+			contentType:        "application/json",
+			body:               `{"usernames": ["alice"], "emails": ["alice@example.com"]}`,
+			expectedStatus:     202,
+			expectedSucceeded:  1,
+			expectedDuplicates: 1,
+		},
+		"csv": {
+			contentType:        "text/csv",
+			body:               "username\nalice\n\nalice@example.com\nmissing\n",
+			expectedStatus:     202,
+			expectedSucceeded:  1,
+			expectedFailed:     1,
+			expectedDuplicates: 1,
+		},
+		"body too large": {
+			contentType:    "text/csv",
+			body:           strings.Repeat("alice\n", maxBulkRemovalBodySize/6+1),
+			expectedStatus: 413,
+			expectedError:  fmt.Sprintf("request body too large, the maximum is %d bytes", maxBulkRemovalBodySize),
+		},
+		"no users": {
+			contentType:    "application/json",
+			body:           `{"usernames": [" "]}`,
+			expectedStatus: 400,
+			expectedError:  "error parsing request: please provide at least one user",
+		},
+		"invalid json": {
+			contentType:    "application/json",
+			body:           "{",
+			expectedStatus: 400,
+			expectedError:  "error parsing request: error decoding users payload: unexpected EOF",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := &Plugin{}
+			api := &plugintest.API{}
+			p.SetAPI(api)
+			setupRemovalJobs(p, api)
+
+			api.On("GetUser", "requesting_user_id").Return(&model.User{
+				Roles: "system_user system_admin",
+			}, nil)
+			mockUsers(api)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/remove_users_from_all_teams_and_channels", strings.NewReader(tc.body))
+			r.Header.Set("Mattermost-User-Id", "requesting_user_id")
+			r.Header.Set("Content-Type", tc.contentType)
+
+			p.ServeHTTP(nil, w, r)
+
+			result := w.Result()
+			require.NotNil(t, result)
+			defer result.Body.Close()
+			bodyBytes, err := io.ReadAll(result.Body)
 			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedStatus, result.StatusCode)
+
 			if tc.expectedError != "" {
+				var errResponse ErrorResponse
+				require.NoError(t, json.Unmarshal(bodyBytes, &errResponse))
 				require.Equal(t, tc.expectedError, errResponse.Error)
-			} else {
-				require.Equal(t, "", errResponse.Error)
+				return
+			}
+
+			var jobResponse RemovalJobResponse
+			require.NoError(t, json.Unmarshal(bodyBytes, &jobResponse))
+
+			p.removalJobs.wg.Wait()
+			job, err := p.getRemovalJob(jobResponse.JobID)
+			require.NoError(t, err)
+			require.NotNil(t, job)
+			require.Equal(t, tc.expectedSucceeded, job.Succeeded)
+			require.Equal(t, tc.expectedFailed, job.Failed)
+			require.Equal(t, tc.expectedDuplicates, job.Duplicates)
+			require.Len(t, job.Results, tc.expectedSucceeded+tc.expectedFailed+tc.expectedDuplicates)
+			for _, res := range job.Results {
+				switch {
+				case res.User == "missing":
+					require.False(t, res.Success)
+					require.NotEmpty(t, res.Error)
+				case res.User == "alice@example.com":
+					require.False(t, res.Success)
+					require.Equal(t, "alice", res.DuplicateOf)
+				default:
+					require.True(t, res.Success)
+					require.NotEmpty(t, res.UserID)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleGetRemovalJob(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+	setupRemovalJobs(p, api)
+
+	api.On("GetUser", "requesting_user_id").Return(&model.User{
+		Roles: "system_user system_admin",
+	}, nil)
+
+	job := &RemovalJob{
+		ID:                model.NewId(),
+		Status:            RemovalJobStatusRunning,
+		UsersTotal:        2,
+		UsersProcessed:    1,
+		TeamsProcessed:    2,
+		ChannelsProcessed: 40,
+		Succeeded:         1,
+	}
+	require.NoError(t, p.saveRemovalJob(job))
+
+	for name, tc := range map[string]struct {
+		method         string
+		path           string
+		expectedStatus int
+		expectedError  string
+	}{
+		"invalid http method": {
+			method:         http.MethodPost,
+			path:           "/jobs/" + job.ID,
+			expectedStatus: 405,
+			expectedError:  "unexpected HTTP method POST. Should be GET",
+		},
+		"invalid job id": {
+			method:         http.MethodGet,
+			path:           "/jobs/invalid",
+			expectedStatus: 400,
+			expectedError:  "invalid job id 'invalid'",
+		},
+		"unknown job": {
+			method:         http.MethodGet,
+			path:           "/jobs/" + "a1b2c3d4e5f6g7h8i9j0k1l2m3",
+			expectedStatus: 404,
+			expectedError:  "no job with id a1b2c3d4e5f6g7h8i9j0k1l2m3",
+		},
+		"job found": {
+			method:         http.MethodGet,
+			path:           "/jobs/" + job.ID,
+			expectedStatus: 200,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			r.Header.Set("Mattermost-User-Id", "requesting_user_id")
+
+			p.ServeHTTP(nil, w, r)
+
+			result := w.Result()
+			require.NotNil(t, result)
+			defer result.Body.Close()
+			bodyBytes, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedStatus, result.StatusCode)
+
+			if tc.expectedError != "" {
+				var errResponse ErrorResponse
+				require.NoError(t, json.Unmarshal(bodyBytes, &errResponse))
+				require.Equal(t, tc.expectedError, errResponse.Error)
+				return
 			}
+
+			var found RemovalJob
+			require.NoError(t, json.Unmarshal(bodyBytes, &found))
+			require.Equal(t, job.ID, found.ID)
+			require.Equal(t, RemovalJobStatusRunning, found.Status)
+			require.Equal(t, 40, found.ChannelsProcessed)
 		})
 	}
 }
+
+// setupRemovalJobs prepares the plugin to run removal jobs against an in-memory KV store.
+func TestCheckRemovalJobInterrupted(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+	setupRemovalJobs(p, api)
+
+	running := &RemovalJob{ID: model.NewId(), Status: RemovalJobStatusRunning}
+	require.NoError(t, p.saveRemovalJob(running))
+	completed := &RemovalJob{ID: model.NewId(), Status: RemovalJobStatusCompleted}
+	require.NoError(t, p.saveRemovalJob(completed))
+	savedAt := model.GetTimeForMillis(running.UpdateAt)
+
+	// still being saved
+	p.checkRemovalJobInterrupted(running, savedAt.Add(time.Minute))
+	require.Equal(t, RemovalJobStatusRunning, running.Status)
+
+	// not saved for too long, such as after a restart
+	p.checkRemovalJobInterrupted(running, savedAt.Add(removalJobStaleTimeout))
+	require.Equal(t, RemovalJobStatusInterrupted, running.Status)
+	stored, err := p.getRemovalJob(running.ID)
+	require.NoError(t, err)
+	require.Equal(t, RemovalJobStatusInterrupted, stored.Status)
+	require.Equal(t, model.GetMillisForTime(savedAt), stored.EndAt)
+
+	p.checkRemovalJobInterrupted(completed, savedAt.Add(removalJobStaleTimeout))
+	require.Equal(t, RemovalJobStatusCompleted, completed.Status)
+}
+
+func setupRemovalJobs(p *Plugin, api *plugintest.API) {
+	p.Client = pluginapi.NewClient(api, nil)
+	p.removalJobs = newRemovalJobRunner()
+
+	var mux sync.Mutex
+	kv := make(map[string][]byte)
+	api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(func(key string, value []byte, _ model.PluginKVSetOptions) bool {
+		mux.Lock()
+		defer mux.Unlock()
+		kv[key] = value
+		return true
+	}, nil)
+	api.On("KVGet", mock.AnythingOfType("string")).Return(func(key string) []byte {
+		mux.Lock()
+		defer mux.Unlock()
+		return kv[key]
+	}, nil)
+	api.On("LogInfo", "Removal job finished", "job_id", mock.Anything, "status", mock.Anything, "succeeded", mock.Anything, "failed", mock.Anything)
+}
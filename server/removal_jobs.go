@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+	routeRemovalJobs = "/jobs/"
+
+	removalJobKeyPrefix    = "removal_job_"
+	removalJobTTL          = time.Hour * 24 * 30
+	removalJobSaveInterval = time.Second * 2
+
+	// removalJobStaleTimeout is how long a pending or running job can go without being saved
+	// before it is considered interrupted, such as by the server restarting. Running jobs are
+	// saved every removalJobSaveInterval while they make progress.
+	removalJobStaleTimeout = time.Minute * 10
+)
+
+type RemovalJobStatus string
+
+const (
+	RemovalJobStatusPending   RemovalJobStatus = "pending"
+	RemovalJobStatusRunning   RemovalJobStatus = "running"
+	RemovalJobStatusCompleted RemovalJobStatus = "completed"
+	RemovalJobStatusCanceled  RemovalJobStatus = "canceled"
+
+	// RemovalJobStatusInterrupted is the status of a job that stopped without finishing, for
+	// example because the server running it restarted.
+	RemovalJobStatusInterrupted RemovalJobStatus = "interrupted"
+)
+
+// RemovalJob is the progress of removing one or more users from all teams and channels in the
+// background. It is stored in the KV store so it can be polled from any server in a cluster.
+type RemovalJob struct {
+	ID                string              `json:"id"`
+	RequesterID       string              `json:"requester_id"`
+	DryRun            bool                `json:"dry_run"` // only plan each removal, leaving the user's memberships as they are
+	Status            RemovalJobStatus    `json:"status"`
+	CreateAt          int64               `json:"create_at"`
+	UpdateAt          int64               `json:"update_at"`
+	EndAt             int64               `json:"end_at,omitempty"`
+	UsersTotal        int                 `json:"users_total"`
+	UsersProcessed    int                 `json:"users_processed"`
+	TeamsTotal        int                 `json:"teams_total"`
+	TeamsProcessed    int                 `json:"teams_processed"`
+	ChannelsTotal     int                 `json:"channels_total"`
+	ChannelsProcessed int                 `json:"channels_processed"`
+	Succeeded         int                 `json:"succeeded"`
+	Failed            int                 `json:"failed"`
+	Duplicates        int                 `json:"duplicates"` // users named more than once, only removed the first time
+	Results           []UserRemovalResult `json:"results"`
+}
+
+type RemovalJobResponse struct {
+	JobID string `json:"job_id"`
+}
+
+func removalJobKey(id string) string {
+	return removalJobKeyPrefix + id
+}
+
+// removalJobRunner runs removal jobs in the background and stops them when the plugin is
+// deactivated.
+type removalJobRunner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newRemovalJobRunner() *removalJobRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &removalJobRunner{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (rr *removalJobRunner) run(f func(ctx context.Context)) {
+	rr.wg.Add(1)
+	go func() {
+		defer rr.wg.Done()
+		f(rr.ctx)
+	}()
+}
+
+// close cancels all running jobs and waits for them to exit.
+func (rr *removalJobRunner) close(timeout time.Duration) error {
+	rr.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		rr.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("waiting on removal jobs to stop timed out after %s", timeout.String())
+	}
+}
+
+// enqueueRemovalJob saves a new job for the users and starts removing them, or planning their
+// removal for a dry run, in the background.
+func (p *Plugin) enqueueRemovalJob(refs []userRef, requesterID string, dryRun bool) (*RemovalJob, error) {
+	now := model.GetMillis()
+	job := &RemovalJob{
+		ID:          model.NewId(),
+		RequesterID: requesterID,
+		DryRun:      dryRun,
+		Status:      RemovalJobStatusPending,
+		CreateAt:    now,
+		UpdateAt:    now,
+		UsersTotal:  len(refs),
+		Results:     make([]UserRemovalResult, 0, len(refs)),
+	}
+	if err := p.saveRemovalJob(job); err != nil {
+		return nil, err
+	}
+
+	p.removalJobs.run(func(ctx context.Context) {
+		p.runRemovalJob(ctx, job, refs)
+	})
+	return job, nil
+}
+
+func (p *Plugin) runRemovalJob(ctx context.Context, job *RemovalJob, refs []userRef) {
+	lastSaved := time.Now()
+	save := func(force bool) {
+		if !force && time.Since(lastSaved) < removalJobSaveInterval {
+			return
+		}
+		if err := p.saveRemovalJob(job); err != nil {
+			p.API.LogError("Error saving removal job", "job_id", job.ID, "err", err.Error())
+		}
+		lastSaved = time.Now()
+	}
+
+	job.Status = RemovalJobStatusRunning
+	save(true)
+
+	seen := make(map[string]string)
+
+	for _, ref := range refs {
+		if ctx.Err() != nil {
+			job.Status = RemovalJobStatusCanceled
+			break
+		}
+
+		// Totals so far from earlier users, to which this user's counts are added.
+		prev := removalCounts{
+			TeamsTotal:        job.TeamsTotal,
+			TeamsProcessed:    job.TeamsProcessed,
+			ChannelsTotal:     job.ChannelsTotal,
+			ChannelsProcessed: job.ChannelsProcessed,
+		}
+		var result UserRemovalResult
+		if job.DryRun {
+			result = p.planUserRef(ref)
+			job.TeamsTotal += result.TeamsTotal
+			job.ChannelsTotal += result.ChannelsTotal
+		} else {
+			result = p.removeUserRef(ctx, ref, job.RequesterID, seen, func(counts removalCounts) {
+				job.TeamsTotal = prev.TeamsTotal + counts.TeamsTotal
+				job.TeamsProcessed = prev.TeamsProcessed + counts.TeamsProcessed
+				job.ChannelsTotal = prev.ChannelsTotal + counts.ChannelsTotal
+				job.ChannelsProcessed = prev.ChannelsProcessed + counts.ChannelsProcessed
+				save(false)
+			})
+		}
+
+		job.UsersProcessed++
+		switch {
+		case result.DuplicateOf != "":
+			job.Duplicates++
+		case result.Success:
+			job.Succeeded++
+		default:
+			job.Failed++
+		}
+		job.Results = append(job.Results, result)
+		save(false)
+	}
+
+	if job.Status == RemovalJobStatusRunning {
+		job.Status = RemovalJobStatusCompleted
+	}
+	job.EndAt = model.GetMillis()
+	save(true)
+
+	p.API.LogInfo("Removal job finished", "job_id", job.ID, "status", string(job.Status), "succeeded", job.Succeeded, "failed", job.Failed)
+}
+
+func (p *Plugin) saveRemovalJob(job *RemovalJob) error {
+	job.UpdateAt = model.GetMillis()
+	if _, err := p.Client.KV.Set(removalJobKey(job.ID), job, pluginapi.SetExpiry(removalJobTTL)); err != nil {
+		return errors.Wrapf(err, "failed to save removal job %s", job.ID)
+	}
+	return nil
+}
+
+// checkRemovalJobInterrupted marks a pending or running job that has not been saved for
+// removalJobStaleTimeout as interrupted, since nothing is running it any more.
+func (p *Plugin) checkRemovalJobInterrupted(job *RemovalJob, now time.Time) {
+	if job.Status != RemovalJobStatusPending && job.Status != RemovalJobStatusRunning {
+		return
+	}
+	if now.Sub(model.GetTimeForMillis(job.UpdateAt)) < removalJobStaleTimeout {
+		return
+	}
+
+	job.Status = RemovalJobStatusInterrupted
+	job.EndAt = job.UpdateAt
+	if err := p.saveRemovalJob(job); err != nil {
+		p.API.LogError("Error saving removal job", "job_id", job.ID, "err", err.Error())
+	}
+}
+
+// getRemovalJob returns the job with the given ID, or nil if there is none.
+func (p *Plugin) getRemovalJob(id string) (*RemovalJob, error) {
+	var job RemovalJob
+	if err := p.Client.KV.Get(removalJobKey(id), &job); err != nil {
+		return nil, errors.Wrapf(err, "failed to get removal job %s", id)
+	}
+	if job.ID == "" {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (p *Plugin) handleGetRemovalJob(w http.ResponseWriter, r *http.Request) {
+	var writeError = func(errorString string, statusCode int) {
+		w.WriteHeader(statusCode)
+		_ = json.NewEncoder(w).Encode(ErrorResponse{errorString})
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(fmt.Sprintf("unexpected HTTP method %s. Should be GET", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	requesterID := r.Header.Get("Mattermost-User-Id")
+	if requesterID == "" {
+		writeError("request is not from an authenticated user", http.StatusUnauthorized)
+		return
+	}
+
+	err := p.ensureSystemAdmin(requesterID)
+	if err != nil {
+		writeError(fmt.Sprintf("error verifying whether user %s is a system admin: %s", requesterID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	jobID := strings.TrimPrefix(r.URL.Path, routeRemovalJobs)
+	if !model.IsValidId(jobID) {
+		writeError(fmt.Sprintf("invalid job id '%s'", jobID), http.StatusBadRequest)
+		return
+	}
+
+	job, err := p.getRemovalJob(jobID)
+	if err != nil {
+		p.API.LogError(err.Error())
+		writeError(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		writeError(fmt.Sprintf("no job with id %s", jobID), http.StatusNotFound)
+		return
+	}
+	p.checkRemovalJobInterrupted(job, time.Now())
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(job)
+}
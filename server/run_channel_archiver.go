@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/jobs"
+)
+
+type RunChannelArchiverPayload struct {
+	Policy string `json:"policy"` // empty for the default policy
+}
+
+// handleRunChannelArchiver runs a scheduled Channel Archiver job immediately.
+func (p *Plugin) handleRunChannelArchiver(w http.ResponseWriter, r *http.Request) {
+	var writeError = func(errorString string, statusCode int) {
+		w.WriteHeader(statusCode)
+		_ = json.NewEncoder(w).Encode(ErrorResponse{errorString})
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(fmt.Sprintf("unexpected HTTP method %s. Should be POST", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	requesterID := r.Header.Get("Mattermost-User-Id")
+	if requesterID == "" {
+		writeError("request is not from an authenticated user", http.StatusUnauthorized)
+		return
+	}
+
+	err := p.ensureSystemAdmin(requesterID)
+	if err != nil {
+		writeError(fmt.Sprintf("error verifying whether user %s is a system admin: %s", requesterID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	// the body is optional; an empty body runs the default policy.
+	var payload RunChannelArchiverPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		writeError(fmt.Sprintf("error decoding payload: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	job, err := jobs.FindChannelArchiverJob(p.jobManager, payload.Policy)
+	if err != nil {
+		writeError(err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := job.RunNow(requesterID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, jobs.ErrJobDisabled) || errors.Is(err, jobs.ErrJobRunning) {
+			status = http.StatusConflict
+		}
+		writeError(err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(SuccessResponse{true})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -13,6 +14,35 @@ import (
 type Payload struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
+	DryRun   bool   `json:"dry_run"` // report what would be removed without removing anything
+}
+
+// RemovalPlan lists the teams and channels a user would be removed from.
+type RemovalPlan struct {
+	UserID              string               `json:"user_id"`
+	Username            string               `json:"username"`
+	TeamCount           int                  `json:"team_count"`
+	ChannelCount        int                  `json:"channel_count"`
+	SkippedChannelCount int                  `json:"skipped_channel_count"`
+	Teams               []TeamRemovalPlan    `json:"teams"`
+	DirectChannels      []ChannelRemovalPlan `json:"direct_channels"` // direct and group messages, which belong to no team
+}
+
+// TeamRemovalPlan lists the user's channels in a team.
+type TeamRemovalPlan struct {
+	TeamID   string               `json:"team_id"`
+	TeamName string               `json:"team_name"`
+	Member   bool                 `json:"member"` // false if the user has left the team but not all of its channels
+	Channels []ChannelRemovalPlan `json:"channels"`
+}
+
+type ChannelRemovalPlan struct {
+	ChannelID   string            `json:"channel_id"`
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name"`
+	Type        model.ChannelType `json:"type"`
+	Skipped     bool              `json:"skipped"`
+	SkipReason  string            `json:"skip_reason,omitempty"`
 }
 
 func (p *Plugin) handleRemoveUserFromAllTeamsAndChannels(w http.ResponseWriter, r *http.Request) {
@@ -38,26 +68,47 @@ func (p *Plugin) handleRemoveUserFromAllTeamsAndChannels(w http.ResponseWriter,
 		return
 	}
 
-	err = p.removeUserFromAllTeamsAndChannels(r, requesterID)
-	if err != nil {
+	var writeProcessingError = func(err error) {
 		err = errors.Wrap(err, "error processing request")
 		p.API.LogError(err.Error())
 		writeError(err.Error(), http.StatusInternalServerError)
+	}
+
+	payload, err := decodePayload(r)
+	if err != nil {
+		writeProcessingError(err)
+		return
+	}
+
+	user, err := p.getPayloadUser(payload)
+	if err != nil {
+		writeProcessingError(err)
+		return
+	}
+
+	job, err := p.enqueueRemovalJob([]userRef{knownUserRef(user)}, requesterID, payload.DryRun)
+	if err != nil {
+		writeProcessingError(err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(SuccessResponse{true})
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(RemovalJobResponse{job.ID})
 }
 
-func (p *Plugin) removeUserFromAllTeamsAndChannels(r *http.Request, requesterID string) error {
+func decodePayload(r *http.Request) (*Payload, error) {
+	defer r.Body.Close()
+
 	var payload Payload
 	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		return errors.Wrap(err, "error decoding user info payload")
+		return nil, errors.Wrap(err, "error decoding user info payload")
 	}
-	r.Body.Close()
+	return &payload, nil
+}
 
+// getPayloadUser returns the user identified by the request payload.
+func (p *Plugin) getPayloadUser(payload *Payload) (*model.User, error) {
 	var user *model.User
 	var appErr *model.AppError
 
@@ -66,28 +117,87 @@ func (p *Plugin) removeUserFromAllTeamsAndChannels(r *http.Request, requesterID
 	case payload.UserID != "":
 		user, appErr = p.API.GetUser(payload.UserID)
 		if appErr != nil {
-			return errors.Wrapf(appErr, "failed to get user with id %s", payload.UserID)
+			return nil, errors.Wrapf(appErr, "failed to get user with id %s", payload.UserID)
 		}
 	case payload.Username != "":
 		user, appErr = p.API.GetUserByUsername(payload.Username)
 		if appErr != nil {
-			return errors.Wrapf(appErr, "failed to get user with username %s", payload.Username)
+			return nil, errors.Wrapf(appErr, "failed to get user with username %s", payload.Username)
 		}
 	default:
-		return errors.New("please provide either user_id or username in the request payload")
+		return nil, errors.New("please provide either user_id or username in the request payload")
 	}
 
+	return user, nil
+}
+
+// membershipPageSize is the number of team or channel memberships fetched at a time.
+const membershipPageSize = 1000
+
+// removalCounts are the number of teams and channels found and processed so far for a user.
+type removalCounts struct {
+	TeamsTotal         int
+	TeamsProcessed     int
+	ChannelsTotal      int
+	ChannelsProcessed  int
+	MembershipsRemoved int // team and channel memberships actually removed
+}
+
+// removalProgress receives the counts each time a team or channel is processed.
+type removalProgress func(counts removalCounts)
+
+// userRemoval is the state of removing one user from all teams and channels.
+type userRemoval struct {
+	user        *model.User
+	requesterID string
+	counts      removalCounts
+	seen        map[string]bool // IDs of channels already processed
+	progress    removalProgress
+}
+
+// RemoveUserFromAllTeamsAndChannels removes the user from every team they belong to and from
+// their channels, and returns the number of team and channel memberships removed.
+func (p *Plugin) RemoveUserFromAllTeamsAndChannels(user *model.User, requesterID string) (int, error) {
+	var removed int
+	err := p.removeUser(context.Background(), user, requesterID, func(counts removalCounts) {
+		removed = counts.MembershipsRemoved
+	})
+	return removed, err
+}
+
+// removeUser removes the user from all teams and channels, reporting progress as it goes. It
+// stops early if the context is canceled.
+func (p *Plugin) removeUser(ctx context.Context, user *model.User, requesterID string, progress removalProgress) error {
 	// Start team/channel removal process
-	teamMembers, appErr := p.API.GetTeamMembersForUser(user.Id, 0, 1000)
-	if appErr != nil {
-		return errors.Wrapf(appErr, "failed to get team members for user. user=%s", user.Username)
+	teamMembers, err := p.getAllTeamMembers(user)
+	if err != nil {
+		return err
+	}
+
+	ur := &userRemoval{
+		user:        user,
+		requesterID: requesterID,
+		counts:      removalCounts{TeamsTotal: len(teamMembers)},
+		seen:        make(map[string]bool),
+		progress:    progress,
 	}
+	progress(ur.counts)
 
 	for _, tm := range teamMembers {
-		err = p.processTeamMember(user, tm.TeamId, requesterID)
+		err := p.processTeamMember(ctx, ur, tm.TeamId)
 		if err != nil {
 			return errors.Wrapf(err, "failed to process team member. user=%s team=%s", user.Username, tm.TeamId)
 		}
+		ur.counts.TeamsProcessed++
+		progress(ur.counts)
+	}
+
+	// A user without teams can still belong to channels, such as those of a team they were
+	// removed from outside of Mattermost, so those are removed directly.
+	if len(teamMembers) == 0 {
+		if err := p.processChannelMembers(ctx, ur, ""); err != nil {
+			return errors.Wrapf(err, "failed to process channel members. user=%s", user.Username)
+		}
 	}
 
 	p.API.LogDebug("Finished for user.", "username", user.Username)
@@ -95,48 +205,201 @@ func (p *Plugin) removeUserFromAllTeamsAndChannels(r *http.Request, requesterID
 	return nil
 }
 
-func (p *Plugin) processTeamMember(user *model.User, teamID string, requesterID string) error {
-	var appErr *model.AppError
-
+func (p *Plugin) processTeamMember(ctx context.Context, ur *userRemoval, teamID string) error {
 	// Remove user from channels in this team
-	channelMembers, appErr := p.API.GetChannelMembersForUser(user.Id, teamID, 0, 1000)
+	if err := p.processChannelMembers(ctx, ur, teamID); err != nil {
+		return err
+	}
+
+	// Remove user from team
+	appErr := p.API.DeleteTeamMember(teamID, ur.user.Id, ur.requesterID)
 	if appErr != nil {
-		return errors.Wrapf(appErr, "failed to get channel members")
+		return errors.Wrap(appErr, "failed to remove user from team")
 	}
+	ur.counts.MembershipsRemoved++
+
+	p.API.LogDebug("Removed user from all channels in team.", "username", ur.user.Username, "team", teamID)
+
+	return nil
+}
 
+// processChannelMembers removes the user from their channels on the team.
+func (p *Plugin) processChannelMembers(ctx context.Context, ur *userRemoval, teamID string) error {
+	channelMembers, err := p.getAllChannelMembers(ur.user, teamID)
+	if err != nil {
+		return err
+	}
+
+	// Memberships are all fetched before any are removed so that removing them does not shift
+	// later pages. Channels already processed for another team are skipped.
+	channelIDs := make([]string, 0, len(channelMembers))
 	for _, cm := range channelMembers {
-		err := p.processChannelMember(user, cm.ChannelId)
-		if err != nil {
-			return errors.Wrapf(err, "failed to process channel member. channel=%s", cm.ChannelId)
+		if !ur.seen[cm.ChannelId] {
+			ur.seen[cm.ChannelId] = true
+			channelIDs = append(channelIDs, cm.ChannelId)
 		}
 	}
+	ur.counts.ChannelsTotal += len(channelIDs)
+	ur.progress(ur.counts)
 
-	// Remove user from team
-	appErr = p.API.DeleteTeamMember(teamID, user.Id, requesterID)
-	if appErr != nil {
-		return errors.Wrap(appErr, "failed to remove user from team")
+	for _, channelID := range channelIDs {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "removal stopped")
+		}
+		removed, err := p.processChannelMember(ur.user, channelID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to process channel member. channel=%s", channelID)
+		}
+		if removed {
+			ur.counts.MembershipsRemoved++
+		}
+		ur.counts.ChannelsProcessed++
+		ur.progress(ur.counts)
 	}
+	return nil
+}
 
-	p.API.LogDebug("Removed user from all channels in team.", "username", user.Username, "team", teamID)
+// getAllTeamMembers returns all of the user's active team memberships, fetching every page.
+// Memberships of teams the user has already left are skipped.
+func (p *Plugin) getAllTeamMembers(user *model.User) ([]*model.TeamMember, error) {
+	var teamMembers []*model.TeamMember
+	for page := 0; ; page++ {
+		members, appErr := p.API.GetTeamMembersForUser(user.Id, page, membershipPageSize)
+		if appErr != nil {
+			return nil, errors.Wrapf(appErr, "failed to get team members for user. user=%s", user.Username)
+		}
+		for _, tm := range members {
+			if tm.DeleteAt == 0 {
+				teamMembers = append(teamMembers, tm)
+			}
+		}
+		if len(members) < membershipPageSize {
+			return teamMembers, nil
+		}
+	}
+}
 
-	return nil
+// getAllChannelMembers returns all of the user's channel memberships on the team, fetching
+// every page.
+func (p *Plugin) getAllChannelMembers(user *model.User, teamID string) ([]*model.ChannelMember, error) {
+	var channelMembers []*model.ChannelMember
+	for page := 0; ; page++ {
+		members, appErr := p.API.GetChannelMembersForUser(teamID, user.Id, page, membershipPageSize)
+		if appErr != nil {
+			return nil, errors.Wrapf(appErr, "failed to get channel members")
+		}
+		channelMembers = append(channelMembers, members...)
+		if len(members) < membershipPageSize {
+			return channelMembers, nil
+		}
+	}
 }
 
-func (p *Plugin) processChannelMember(user *model.User, channelID string) error {
+// processChannelMember removes the user from the channel and reports whether they were
+// removed. Channels the user cannot leave are skipped.
+func (p *Plugin) processChannelMember(user *model.User, channelID string) (bool, error) {
 	// Remove user from channel
 	appErr := p.API.DeleteChannelMember(channelID, user.Id)
 	if appErr != nil {
 		c, channelErr := p.API.GetChannel(channelID)
 		if channelErr != nil {
-			return errors.Wrapf(channelErr, "failed to get channel %s", channelID)
+			return false, errors.Wrapf(channelErr, "failed to get channel %s", channelID)
 		}
 
-		if c.Name == model.DefaultChannelName {
-			return nil
+		if channelRemovalSkipReason(c) != "" {
+			return false, nil
 		}
 
-		return errors.Wrap(appErr, "failed to remove user from channel")
+		return false, errors.Wrap(appErr, "failed to remove user from channel")
 	}
 
-	return nil
+	return true, nil
+}
+
+// channelRemovalSkipReason returns why a user cannot be removed from the channel, or an empty
+// string if they can.
+func channelRemovalSkipReason(c *model.Channel) string {
+	// users cannot leave the default channel, direct and group messages, or archived channels.
+	switch {
+	case c.Name == model.DefaultChannelName:
+		return "default channel"
+	case c.IsGroupOrDirect():
+		return "direct or group message"
+	case c.DeleteAt > 0:
+		return "archived channel"
+	}
+	return ""
+}
+
+// planUserRemoval lists the teams and channels the user would be removed from, without
+// removing them. Channels are grouped by the team they belong to.
+func (p *Plugin) planUserRemoval(user *model.User) (*RemovalPlan, error) {
+	plan := &RemovalPlan{
+		UserID:         user.Id,
+		Username:       user.Username,
+		Teams:          []TeamRemovalPlan{},
+		DirectChannels: []ChannelRemovalPlan{},
+	}
+
+	teams, appErr := p.API.GetTeamsForUser(user.Id)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get teams for user. user=%s", user.Username)
+	}
+
+	// Without a team ID this returns the user's channels in every team, and archived ones too.
+	channels, appErr := p.API.GetChannelsForTeamForUser("", user.Id, true)
+	if appErr != nil && appErr.StatusCode != http.StatusNotFound {
+		return nil, errors.Wrapf(appErr, "failed to get channels for user. user=%s", user.Username)
+	}
+
+	teamIndex := make(map[string]int, len(teams)) // team ID to its position in plan.Teams
+	for _, team := range teams {
+		teamIndex[team.Id] = len(plan.Teams)
+		plan.Teams = append(plan.Teams, TeamRemovalPlan{
+			TeamID:   team.Id,
+			TeamName: team.Name,
+			Member:   true,
+			Channels: []ChannelRemovalPlan{},
+		})
+	}
+	plan.TeamCount = len(teams)
+
+	for _, c := range channels {
+		channelPlan := ChannelRemovalPlan{
+			ChannelID:   c.Id,
+			Name:        c.Name,
+			DisplayName: c.DisplayName,
+			Type:        c.Type,
+			SkipReason:  channelRemovalSkipReason(c),
+		}
+		if channelPlan.SkipReason != "" {
+			channelPlan.Skipped = true
+			plan.SkippedChannelCount++
+		} else {
+			plan.ChannelCount++
+		}
+
+		if c.TeamId == "" {
+			plan.DirectChannels = append(plan.DirectChannels, channelPlan)
+			continue
+		}
+
+		i, ok := teamIndex[c.TeamId]
+		if !ok {
+			team, appErr := p.API.GetTeam(c.TeamId)
+			if appErr != nil {
+				return nil, errors.Wrapf(appErr, "failed to get team %s", c.TeamId)
+			}
+			i = len(plan.Teams)
+			teamIndex[c.TeamId] = i
+			plan.Teams = append(plan.Teams, TeamRemovalPlan{
+				TeamID:   team.Id,
+				TeamName: team.Name,
+				Channels: []ChannelRemovalPlan{},
+			})
+		}
+		plan.Teams[i].Channels = append(plan.Teams[i].Channels, channelPlan)
+	}
+
+	return plan, nil
 }
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"database/sql"
+	"sort"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -12,35 +14,159 @@ var (
 	defaultChannels = []string{"town-square", "off-topic"}
 )
 
+const (
+	activityChunkSize = 500
+)
+
 type StaleChannelOpts struct {
 	AgeInDays                 int
-	ExcludeChannels           []string
+	AllMembersDeactivated     bool     // channels whose members are all deactivated users or bots, regardless of activity
+	EmptyOnly                 bool     // only channels without any user posts
+	MinMembers                int      // only channels with at least this many members; zero means no minimum
+	MaxMembers                int      // only channels with at most this many members; zero means no maximum
+	IncludeChannels           []string // channel names, IDs, or patterns; empty means all channels
+	ExcludeChannels           []string // channel names, IDs, or patterns (see patterns.go)
 	IncludeChannelTypeOpen    bool
 	IncludeChannelTypePrivate bool
 	IncludeChannelTypeDirect  bool
 	IncludeChannelTypeGroup   bool
+	IgnorePostsByUsers        []string       // posts by these users (e.g. the archiver bot) do not count as activity
+	IncludeTeams              []string       // team IDs; empty means all teams
+	ExcludeTeams              []string       // team IDs
+	TeamAgeInDays             map[string]int // AgeInDays overrides keyed by team ID
+}
+
+// AgeInDaysForTeam returns the number of days of inactivity after which channels in the team are stale.
+func (opts StaleChannelOpts) AgeInDaysForTeam(teamID string) int {
+	if days, ok := opts.TeamAgeInDays[teamID]; ok {
+		return days
+	}
+	return opts.AgeInDays
 }
 
-func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize int) ([]*model.Channel, bool, error) {
-	olderThan := model.GetMillisForTime(time.Now().AddDate(0, 0, -opts.AgeInDays))
+// ActivitySource is where a channel's most recent activity came from.
+type ActivitySource string
+
+const (
+	ActivitySourceChannel  ActivitySource = "channel"  // the channel itself was created or updated
+	ActivitySourcePost     ActivitySource = "post"     // a post was created, edited or deleted
+	ActivitySourceReaction ActivitySource = "reaction" // a reaction was added or removed
+)
+
+// StaleChannel is a channel found by GetStaleChannels, with details for reporting.
+type StaleChannel struct {
+	*model.Channel
+	TeamName           string // empty for direct and group message channels
+	CreatorName        string // username of the channel creator, if known
+	MemberCount        int64
+	LastActivityAt     int64 // most recent update or delete of the channel, its posts or their reactions
+	LastActivitySource ActivitySource
+}
+
+// IdleDays returns the number of whole days since the channel's last activity.
+func (sc *StaleChannel) IdleDays(now time.Time) int {
+	return int(now.Sub(model.GetTimeForMillis(sc.LastActivityAt)).Hours() / 24)
+}
+
+// setLastActivity sets the most recent of the channel, post and reaction activity timestamps.
+func (sc *StaleChannel) setLastActivity(channelAt int64, postAt sql.NullInt64, reactionAt sql.NullInt64) {
+	sc.LastActivityAt, sc.LastActivitySource = channelAt, ActivitySourceChannel
+	if postAt.Valid && postAt.Int64 > sc.LastActivityAt {
+		sc.LastActivityAt, sc.LastActivitySource = postAt.Int64, ActivitySourcePost
+	}
+	if reactionAt.Valid && reactionAt.Int64 > sc.LastActivityAt {
+		sc.LastActivityAt, sc.LastActivitySource = reactionAt.Int64, ActivitySourceReaction
+	}
+}
+
+func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize int) ([]*StaleChannel, bool, error) {
+	now := time.Now()
 
 	excludeChannels := make([]string, 0)
 	excludeChannels = append(excludeChannels, opts.ExcludeChannels...)
 	excludeChannels = append(excludeChannels, defaultChannels...)
 
-	// find all channels where no posts or reactions have been modified,deleted since the olderThan timestamp.
-	query := ss.builder.Select("ch.id", "ch.name").Distinct().
+	postsJoin := "posts as p ON ch.id=p.channelid"
+	var postsJoinArgs []interface{}
+	if len(opts.IgnorePostsByUsers) > 0 {
+		postsJoin += " AND p.userid NOT IN (" + sq.Placeholders(len(opts.IgnorePostsByUsers)) + ")"
+		for _, userID := range opts.IgnorePostsByUsers {
+			postsJoinArgs = append(postsJoinArgs, userID)
+		}
+	}
+
+	query := ss.builder.Select("ch.id", "ch.name", "ch.displayname", "ch.teamid", "ch.type", "ch.creatorid", "ch.createat", "ch.lastpostat", "ch.updateat").
+		Columns("COALESCE(t.name, '')", "COALESCE(cu.username, '')").
+		Columns("(SELECT COUNT(*) FROM channelmembers as mc WHERE mc.channelid=ch.id)").
+		Distinct().
 		From("channels as ch").
-		LeftJoin("posts as p ON ch.id=p.channelid").
-		LeftJoin("reactions as r ON p.id=r.postid"). // reactions.channelid does not exist in all versions of server
+		LeftJoin("teams as t ON t.id=ch.teamid").
+		LeftJoin("users as cu ON cu.id=ch.creatorid").
+		LeftJoin(keepAliveTable + " as ka ON ch.id=ka.channelid").
 		Where(sq.Eq{"ch.deleteat": 0}).
-		Where(sq.Lt{"ch.updateat": olderThan}).
-		Where(sq.Or{sq.Eq{"p.updateat": nil}, sq.Lt{"p.updateat": olderThan, "p.deleteat": olderThan}}).
-		Where(sq.Or{sq.Eq{"r.updateat": nil}, sq.Lt{"r.updateat": olderThan, "r.deleteat": olderThan}}).
+		Where(sq.Or{sq.Eq{"ka.channelid": nil}, sq.And{sq.Gt{"ka.expireat": 0}, sq.Lt{"ka.expireat": model.GetMillis()}}}).
 		OrderBy("ch.id")
 
-	if len(excludeChannels) > 0 {
-		query = query.Where(sq.NotEq{"ch.id": excludeChannels, "ch.name": excludeChannels})
+	if opts.AllMembersDeactivated {
+		// find all channels that have members, none of whom is an active person. Channels
+		// without any members are left to EmptyOnly and the usual activity check.
+		anyMembers := sq.Select("1").
+			From("channelmembers as anym").
+			Where("anym.channelid=ch.id")
+		activeMembers := sq.Select("1").
+			From("channelmembers as am").
+			Join("users as u ON u.id=am.userid").
+			LeftJoin("bots as b ON b.userid=u.id").
+			Where("am.channelid=ch.id").
+			Where(sq.Eq{"u.deleteat": 0, "b.userid": nil})
+		query = query.
+			Where(sq.Expr("EXISTS (?)", anyMembers)).
+			Where(sq.Expr("NOT EXISTS (?)", activeMembers))
+	} else {
+		// find all channels where no posts or reactions have been modified,deleted since the olderThan timestamp.
+		query = query.
+			LeftJoin(postsJoin, postsJoinArgs...).
+			LeftJoin("reactions as r ON p.id=r.postid") // reactions.channelid does not exist in all versions of server
+		query = query.Where(staleCondition(opts, now))
+	}
+
+	if opts.EmptyOnly {
+		// system messages (joins, header changes, etc.) and posts by ignored users don't count.
+		userPosts := sq.Select("1").
+			From("posts as ep").
+			Where("ep.channelid=ch.id").
+			Where(sq.Eq{"ep.deleteat": 0}).
+			Where(sq.NotLike{"ep.type": model.PostSystemMessagePrefix + "%"})
+		if len(opts.IgnorePostsByUsers) > 0 {
+			userPosts = userPosts.Where(sq.NotEq{"ep.userid": opts.IgnorePostsByUsers})
+		}
+		query = query.Where(sq.Expr("NOT EXISTS (?)", userPosts))
+	}
+
+	if opts.MinMembers > 0 || opts.MaxMembers > 0 {
+		memberCount := sq.Select("COUNT(*)").
+			From("channelmembers as cm").
+			Where("cm.channelid=ch.id")
+		if opts.MinMembers > 0 {
+			query = query.Where(sq.Expr("(?) >= ?", memberCount, opts.MinMembers))
+		}
+		if opts.MaxMembers > 0 {
+			query = query.Where(sq.Expr("(?) <= ?", memberCount, opts.MaxMembers))
+		}
+	}
+
+	if len(opts.IncludeTeams) > 0 {
+		query = query.Where(sq.Eq{"ch.teamid": opts.IncludeTeams})
+	}
+	if len(opts.ExcludeTeams) > 0 {
+		query = query.Where(sq.NotEq{"ch.teamid": opts.ExcludeTeams})
+	}
+
+	if include := ss.matchChannels(opts.IncludeChannels); include != nil {
+		query = query.Where(include)
+	}
+	if exclude := ss.matchChannels(excludeChannels); exclude != nil {
+		query = query.Where(sq.Expr("NOT (?)", exclude))
 	}
 
 	channelTypes := []string{}
@@ -56,6 +182,18 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 	if opts.IncludeChannelTypeGroup {
 		channelTypes = append(channelTypes, string(model.ChannelTypeGroup))
 	}
+	if opts.IncludeChannelTypeDirect || opts.IncludeChannelTypeGroup {
+		// direct and group message channels are hidden rather than archived, so skip those
+		// already hidden unless someone has posted since.
+		query = query.
+			LeftJoin(channelStateTable + " as cs ON ch.id=cs.channelid").
+			Where(sq.Or{
+				sq.Eq{"ch.type": []string{string(model.ChannelTypeOpen), string(model.ChannelTypePrivate)}},
+				sq.Eq{"cs.archivedat": nil},
+				sq.Eq{"cs.archivedat": 0},
+				sq.Expr("ch.lastpostat > cs.archivedat"),
+			})
+	}
 	query = query.Where(sq.Eq{"ch.type": channelTypes})
 
 	if page > 0 {
@@ -72,17 +210,24 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 		ss.logger.Error("error fetching stale channels", "err", err)
 		return nil, false, err
 	}
+	defer rows.Close()
 
-	channels := []*model.Channel{}
+	channels := []*StaleChannel{}
 	for rows.Next() {
-		channel := &model.Channel{}
+		channel := &StaleChannel{Channel: &model.Channel{}}
 
-		if err := rows.Scan(&channel.Id, &channel.Name); err != nil {
+		if err := rows.Scan(&channel.Id, &channel.Name, &channel.DisplayName, &channel.TeamId, &channel.Type,
+			&channel.CreatorId, &channel.CreateAt, &channel.LastPostAt, &channel.UpdateAt,
+			&channel.TeamName, &channel.CreatorName, &channel.MemberCount); err != nil {
 			ss.logger.Error("error scanning stale channels", "err", err)
 			return nil, false, err
 		}
 		channels = append(channels, channel)
 	}
+	if err := rows.Err(); err != nil {
+		ss.logger.Error("error fetching stale channels", "err", err)
+		return nil, false, err
+	}
 
 	var hasMore bool
 	if pageSize > 0 && len(channels) > pageSize {
@@ -90,5 +235,148 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 		channels = channels[0:pageSize]
 	}
 
+	if err := ss.fetchLastActivity(channels, opts.IgnorePostsByUsers); err != nil {
+		return nil, false, err
+	}
+
 	return channels, hasMore, nil
 }
+
+// fetchLastActivity fetches the most recent post and reaction activity for the channels being
+// returned, counting the same posts as the stale check. It is fetched separately from the stale
+// channel query so that only the returned channels are read, in chunks of activityChunkSize.
+func (ss *SQLStore) fetchLastActivity(channels []*StaleChannel, ignorePostsByUsers []string) error {
+	for start := 0; start < len(channels); start += activityChunkSize {
+		end := start + activityChunkSize
+		if end > len(channels) {
+			end = len(channels)
+		}
+		chunk := channels[start:end]
+
+		channelIDs := make([]string, 0, len(chunk))
+		for _, channel := range chunk {
+			channelIDs = append(channelIDs, channel.Id)
+		}
+
+		// separate maximums of updateat and deleteat can be read from the posts indexes on
+		// (channelid, updateat) and (channelid, deleteat). Reaction timestamps are nullable.
+		postQuery := ss.builder.Select("p.channelid", "COALESCE(MAX(p.updateat), 0)", "COALESCE(MAX(p.deleteat), 0)").
+			From("posts as p").
+			Where(sq.Eq{"p.channelid": channelIDs}).
+			GroupBy("p.channelid")
+		reactionQuery := ss.builder.Select("p.channelid", "COALESCE(MAX(r.updateat), 0)", "COALESCE(MAX(r.deleteat), 0)").
+			From("reactions as r").
+			Join("posts as p ON p.id=r.postid"). // reactions.channelid does not exist in all versions of server
+			Where(sq.Eq{"p.channelid": channelIDs}).
+			GroupBy("p.channelid")
+		if len(ignorePostsByUsers) > 0 {
+			postQuery = postQuery.Where(sq.NotEq{"p.userid": ignorePostsByUsers})
+			reactionQuery = reactionQuery.Where(sq.NotEq{"p.userid": ignorePostsByUsers})
+		}
+
+		postActivity, err := ss.queryLastActivity(postQuery)
+		if err != nil {
+			ss.logger.Error("error fetching last post activity", "err", err)
+			return err
+		}
+		reactionActivity, err := ss.queryLastActivity(reactionQuery)
+		if err != nil {
+			ss.logger.Error("error fetching last reaction activity", "err", err)
+			return err
+		}
+
+		for _, channel := range chunk {
+			channel.setLastActivity(channel.UpdateAt, postActivity[channel.Id], reactionActivity[channel.Id])
+		}
+	}
+	return nil
+}
+
+// queryLastActivity runs a query returning channel ID, max updateat and max deleteat, and
+// returns the later of the two timestamps keyed by channel ID.
+func (ss *SQLStore) queryLastActivity(query sq.SelectBuilder) (map[string]sql.NullInt64, error) {
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := make(map[string]sql.NullInt64)
+	for rows.Next() {
+		var channelID string
+		var updateAt, deleteAt int64
+		if err := rows.Scan(&channelID, &updateAt, &deleteAt); err != nil {
+			return nil, err
+		}
+		if deleteAt > updateAt {
+			updateAt = deleteAt
+		}
+		activity[channelID] = sql.NullInt64{Int64: updateAt, Valid: true}
+	}
+	return activity, rows.Err()
+}
+
+// staleCondition returns the conditions for a channel to be stale, applying any per-team
+// AgeInDays overrides.
+func staleCondition(opts StaleChannelOpts, now time.Time) sq.Sqlizer {
+	olderThan := model.GetMillisForTime(now.AddDate(0, 0, -opts.AgeInDays))
+	if len(opts.TeamAgeInDays) == 0 {
+		return staleSince(olderThan)
+	}
+
+	overrideTeams := make([]string, 0, len(opts.TeamAgeInDays))
+	for teamID := range opts.TeamAgeInDays {
+		overrideTeams = append(overrideTeams, teamID)
+	}
+	sort.Strings(overrideTeams)
+
+	stale := sq.Or{sq.And{sq.NotEq{"ch.teamid": overrideTeams}, staleSince(olderThan)}}
+	for _, teamID := range overrideTeams {
+		teamOlderThan := model.GetMillisForTime(now.AddDate(0, 0, -opts.TeamAgeInDays[teamID]))
+		stale = append(stale, sq.And{sq.Eq{"ch.teamid": teamID}, staleSince(teamOlderThan)})
+	}
+	return stale
+}
+
+// staleSince returns the conditions for a channel, its posts and their reactions to have had no
+// activity since the olderThan timestamp.
+func staleSince(olderThan int64) sq.And {
+	return sq.And{
+		sq.Lt{"ch.updateat": olderThan},
+		sq.Or{sq.Eq{"p.updateat": nil}, sq.Lt{"p.updateat": olderThan, "p.deleteat": olderThan}},
+		sq.Or{sq.Eq{"r.updateat": nil}, sq.Lt{"r.updateat": olderThan, "r.deleteat": olderThan}},
+	}
+}
+
+// GetChannelOwnerIDs returns the IDs of the channel's creator and channel admins, excluding
+// deactivated users and bots.
+func (ss *SQLStore) GetChannelOwnerIDs(channelID string, creatorID string) ([]string, error) {
+	admins := sq.Select("cm.userid").
+		From("channelmembers as cm").
+		Where(sq.Eq{"cm.channelid": channelID, "cm.schemeadmin": true})
+
+	query := ss.builder.Select("u.id").
+		From("users as u").
+		LeftJoin("bots as b ON b.userid=u.id").
+		Where(sq.Eq{"u.deleteat": 0, "b.userid": nil}).
+		Where(sq.Or{sq.Eq{"u.id": creatorID}, sq.Expr("u.id IN (?)", admins)}).
+		OrderBy("u.id")
+
+	rows, err := query.Query()
+	if err != nil {
+		ss.logger.Error("error fetching channel owners", "channel_id", channelID, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			ss.logger.Error("error scanning channel owners", "channel_id", channelID, "err", err)
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
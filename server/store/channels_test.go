@@ -1,6 +1,7 @@
 package store
 
 import (
+	"database/sql"
 	"testing"
 	"time"
 
@@ -140,7 +141,7 @@ func TestSQLStore_GetStaleChannelsPagnation(t *testing.T) {
 
 	const pageSize = 10
 	var page = 0
-	staleChannels := make([]*model.Channel, 0)
+	staleChannels := make([]*StaleChannel, 0)
 	loopCount := 0
 
 	opts := StaleChannelOpts{
@@ -166,7 +167,10 @@ func TestSQLStore_GetStaleChannelsPagnation(t *testing.T) {
 	assert.Len(t, staleChannels, 50)
 
 	staleIDs := extractChannelIDs(staleChannels)
-	channelIDs := extractChannelIDs(channels[:50])
+	channelIDs := make([]string, 0, 50)
+	for _, ch := range channels[:50] {
+		channelIDs = append(channelIDs, ch.Id)
+	}
 	assert.ElementsMatch(t, staleIDs, channelIDs)
 }
 
@@ -204,6 +208,319 @@ func TestSQLStore_GetStaleChannelsExclude(t *testing.T) {
 	assert.ElementsMatch(t, staleIDs, []string{channels[3].Id, channels[4].Id})
 }
 
+func TestSQLStore_GetStaleChannelsPatterns(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	incidents, err := th.CreateChannels(2, "incident", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+	legal, err := th.CreateChannels(2, "legal", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+	others, err := th.CreateChannels(2, "pattern-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	for _, channels := range [][]*model.Channel{incidents, legal, others} {
+		for _, ch := range channels {
+			setTimestamps(t, th, "channels", ch.Id, yearAgo, yearAgo, 0)
+		}
+	}
+
+	fetch := func(exclude []string) []string {
+		opts := StaleChannelOpts{
+			AgeInDays:              30,
+			IncludeChannelTypeOpen: true,
+			IncludeTeams:           []string{th.Team1.Id},
+			ExcludeChannels:        exclude,
+		}
+		staleChannels, _, err := th.Store.GetStaleChannels(opts, 0, 0)
+		require.NoError(t, err)
+		return extractChannelIDs(staleChannels)
+	}
+
+	staleIDs := fetch([]string{"incident-*", "re:^LEGAL-", others[0].Name})
+	assert.ElementsMatch(t, staleIDs, []string{others[1].Id})
+
+	staleIDs = fetch([]string{"*-1"})
+	assert.ElementsMatch(t, staleIDs, []string{incidents[0].Id, legal[0].Id, others[0].Id})
+
+	// include only incident and legal channels, then exclude one of them
+	opts := StaleChannelOpts{
+		AgeInDays:              30,
+		IncludeChannelTypeOpen: true,
+		IncludeChannels:        []string{"incident-*", "re:^legal-"},
+		ExcludeChannels:        []string{incidents[1].Id},
+	}
+	staleChannels, _, err := th.Store.GetStaleChannels(opts, 0, 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, extractChannelIDs(staleChannels), []string{incidents[0].Id, legal[0].Id, legal[1].Id})
+}
+
+func TestSQLStore_GetStaleChannelsIgnorePostsByUsers(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	const channelCount = 2
+
+	channels, err := th.CreateChannels(channelCount, "ignore-posts-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	// both channels are old, but each has a recent post by User2 (e.g. a bot warning)
+	for _, ch := range channels {
+		setTimestamps(t, th, "channels", ch.Id, yearAgo, yearAgo, 0)
+		_, err = th.CreatePosts(1, th.User2.Id, ch.Id)
+		require.NoError(t, err)
+	}
+
+	// channel 1 also has a recent post by User1
+	_, err = th.CreatePosts(1, th.User1.Id, channels[1].Id)
+	require.NoError(t, err)
+
+	opts := StaleChannelOpts{
+		AgeInDays:              30,
+		IncludeChannelTypeOpen: true,
+	}
+	staleChannels, _, err := th.Store.GetStaleChannels(opts, 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, staleChannels)
+
+	// ignoring User2's posts makes channel 0 stale
+	opts.IgnorePostsByUsers = []string{th.User2.Id}
+	staleChannels, more, err := th.Store.GetStaleChannels(opts, 0, 0)
+	require.NoError(t, err)
+	assert.False(t, more)
+
+	staleIDs := extractChannelIDs(staleChannels)
+	assert.ElementsMatch(t, staleIDs, []string{channels[0].Id})
+}
+
+func TestSQLStore_GetStaleChannelsKeepAlive(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	const channelCount = 4
+
+	channels, err := th.CreateChannels(channelCount, "keep-alive-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	for _, ch := range channels {
+		setTimestamps(t, th, "channels", ch.Id, yearAgo, yearAgo, 0)
+	}
+
+	// channel 0 - kept indefinitely (not stale)
+	// channel 1 - kept until next week (not stale)
+	// channel 2 - kept until last week (stale)
+	// channel 3 - kept indefinitely, then removed (stale)
+	nextWeek := model.GetMillisForTime(time.Now().AddDate(0, 0, 7))
+	keepAlives := []*KeepAlive{
+		{ChannelID: channels[0].Id, UserID: th.User1.Id, CreateAt: weekAgo},
+		{ChannelID: channels[1].Id, UserID: th.User1.Id, CreateAt: weekAgo, ExpireAt: nextWeek},
+		{ChannelID: channels[2].Id, UserID: th.User1.Id, CreateAt: yearAgo, ExpireAt: weekAgo},
+		{ChannelID: channels[3].Id, UserID: th.User1.Id, CreateAt: weekAgo},
+	}
+	for _, ka := range keepAlives {
+		require.NoError(t, th.Store.SaveKeepAlive(ka))
+	}
+	require.NoError(t, th.Store.DeleteKeepAlive(channels[3].Id))
+
+	fetched, err := th.Store.GetKeepAlive(channels[1].Id)
+	require.NoError(t, err)
+	assert.Equal(t, keepAlives[1], fetched)
+
+	fetched, err = th.Store.GetKeepAlive(channels[3].Id)
+	require.NoError(t, err)
+	assert.Nil(t, fetched)
+
+	opts := StaleChannelOpts{
+		AgeInDays:              30,
+		IncludeChannelTypeOpen: true,
+	}
+	staleChannels, more, err := th.Store.GetStaleChannels(opts, 0, 0)
+	require.NoError(t, err)
+	assert.False(t, more)
+
+	staleIDs := extractChannelIDs(staleChannels)
+	assert.ElementsMatch(t, staleIDs, []string{channels[2].Id, channels[3].Id})
+}
+
+func TestSQLStore_GetStaleChannelsTeams(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	twoMonthsAgo := model.GetMillisForTime(time.Now().AddDate(0, 0, -60))
+
+	team1Channels, err := th.CreateChannels(2, "team1-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+	team2Channels, err := th.CreateChannels(2, "team2-test", th.User1.Id, th.Team2.Id)
+	require.NoError(t, err)
+
+	// channel 0 of each team idle for two months, channel 1 idle for a year
+	for _, channels := range [][]*model.Channel{team1Channels, team2Channels} {
+		setTimestamps(t, th, "channels", channels[0].Id, twoMonthsAgo, twoMonthsAgo, 0)
+		setTimestamps(t, th, "channels", channels[1].Id, yearAgo, yearAgo, 0)
+	}
+
+	fetch := func(opts StaleChannelOpts) []string {
+		opts.IncludeChannelTypeOpen = true
+		staleChannels, more, err := th.Store.GetStaleChannels(opts, 0, 0)
+		require.NoError(t, err)
+		assert.False(t, more)
+		return extractChannelIDs(staleChannels)
+	}
+
+	staleIDs := fetch(StaleChannelOpts{AgeInDays: 90})
+	assert.ElementsMatch(t, staleIDs, []string{team1Channels[1].Id, team2Channels[1].Id})
+
+	staleIDs = fetch(StaleChannelOpts{AgeInDays: 90, TeamAgeInDays: map[string]int{th.Team1.Id: 30}})
+	assert.ElementsMatch(t, staleIDs, []string{team1Channels[0].Id, team1Channels[1].Id, team2Channels[1].Id})
+
+	staleIDs = fetch(StaleChannelOpts{AgeInDays: 90, IncludeTeams: []string{th.Team2.Id}})
+	assert.ElementsMatch(t, staleIDs, []string{team2Channels[1].Id})
+
+	staleIDs = fetch(StaleChannelOpts{AgeInDays: 30, ExcludeTeams: []string{th.Team2.Id}})
+	assert.ElementsMatch(t, staleIDs, []string{team1Channels[0].Id, team1Channels[1].Id})
+}
+
+func TestSQLStore_GetStaleChannelsEmptyOnly(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	channels, err := th.CreateChannels(2, "empty-only-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	// channel 1 has user posts; both channels idle for a year
+	_, err = th.CreatePosts(3, th.User1.Id, channels[1].Id)
+	require.NoError(t, err)
+	for _, channel := range channels {
+		setTimestamps(t, th, "channels", channel.Id, yearAgo, yearAgo, 0)
+		setTimestamps(t, th, "posts", channel.Id, yearAgo, yearAgo, 0)
+	}
+
+	opts := StaleChannelOpts{
+		AgeInDays:              30,
+		IncludeChannelTypeOpen: true,
+	}
+	staleChannels, _, err := th.Store.GetStaleChannels(opts, 0, 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, extractChannelIDs(staleChannels), []string{channels[0].Id, channels[1].Id})
+
+	opts.EmptyOnly = true
+	staleChannels, _, err = th.Store.GetStaleChannels(opts, 0, 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, extractChannelIDs(staleChannels), []string{channels[0].Id})
+}
+
+func TestSQLStore_GetStaleChannelsMembers(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	// channel i has i members
+	channels, err := th.CreateChannels(3, "members-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+	require.NoError(t, th.AddChannelMembers(channels[1].Id, th.User1.Id))
+	require.NoError(t, th.AddChannelMembers(channels[2].Id, th.User1.Id, th.User2.Id))
+	for _, ch := range channels {
+		setTimestamps(t, th, "channels", ch.Id, yearAgo, yearAgo, 0)
+	}
+
+	fetch := func(minMembers, maxMembers int) []string {
+		opts := StaleChannelOpts{
+			AgeInDays:              30,
+			IncludeChannelTypeOpen: true,
+			IncludeChannels:        []string{"members-test-*"},
+			MinMembers:             minMembers,
+			MaxMembers:             maxMembers,
+		}
+		staleChannels, _, err := th.Store.GetStaleChannels(opts, 0, 0)
+		require.NoError(t, err)
+		return extractChannelIDs(staleChannels)
+	}
+
+	assert.ElementsMatch(t, fetch(0, 0), []string{channels[0].Id, channels[1].Id, channels[2].Id})
+	assert.ElementsMatch(t, fetch(1, 0), []string{channels[1].Id, channels[2].Id})
+	assert.ElementsMatch(t, fetch(0, 1), []string{channels[0].Id, channels[1].Id})
+	assert.ElementsMatch(t, fetch(1, 1), []string{channels[1].Id})
+}
+
+func TestSQLStore_GetStaleChannelsAllMembersDeactivated(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	users, err := th.CreateUsers(2, "deactivated-test")
+	require.NoError(t, err)
+	require.NoError(t, th.DeactivateUsers(users[0].Id, users[1].Id))
+	bot, err := th.CreateBot("deactivated-test-bot")
+	require.NoError(t, err)
+
+	// channels have recent activity, which is ignored for this criterion. The last channel has
+	// no members at all, which is not the same as all members being deactivated.
+	channels, err := th.CreateChannels(4, "deactivated-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+	require.NoError(t, th.AddChannelMembers(channels[0].Id, users[0].Id, users[1].Id))
+	require.NoError(t, th.AddChannelMembers(channels[1].Id, users[0].Id, bot.Id))
+	require.NoError(t, th.AddChannelMembers(channels[2].Id, users[0].Id, th.User2.Id))
+
+	opts := StaleChannelOpts{
+		AllMembersDeactivated:  true,
+		IncludeChannelTypeOpen: true,
+		IncludeChannels:        []string{"deactivated-test-*"},
+	}
+	staleChannels, _, err := th.Store.GetStaleChannels(opts, 0, 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, extractChannelIDs(staleChannels), []string{channels[0].Id, channels[1].Id})
+}
+
+func TestSQLStore_GetStaleChannelsDirect(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	dm, err := th.CreateDirectChannel(th.User1, th.User2)
+	require.NoError(t, err)
+	setTimestamps(t, th, "channels", dm.Id, yearAgo, yearAgo, 0)
+
+	fetch := func(includeDirect bool) []string {
+		opts := StaleChannelOpts{
+			AgeInDays:                30,
+			IncludeChannelTypeDirect: includeDirect,
+		}
+		staleChannels, _, err := th.Store.GetStaleChannels(opts, 0, 0)
+		require.NoError(t, err)
+		return extractChannelIDs(staleChannels)
+	}
+
+	assert.Empty(t, fetch(false))
+	assert.Equal(t, []string{dm.Id}, fetch(true))
+
+	// hidden channels are skipped until someone posts again
+	err = th.Store.SaveChannelState(&ChannelState{ChannelID: dm.Id, ArchivedAt: model.GetMillis()})
+	require.NoError(t, err)
+	assert.Empty(t, fetch(true))
+}
+
+func TestSQLStore_GetChannelOwnerIDs(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	users, err := th.CreateUsers(3, "owners-test")
+	require.NoError(t, err)
+	require.NoError(t, th.DeactivateUsers(users[2].Id))
+
+	channels, err := th.CreateChannels(1, "owners-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+	channel := channels[0]
+
+	// users 0 and 2 are channel admins, user 1 is a regular member
+	require.NoError(t, th.AddChannelMembers(channel.Id, users[0].Id, users[1].Id, users[2].Id))
+	_, err = th.Store.builder.Update("channelmembers").
+		Set("schemeadmin", true).
+		Where(sq.Eq{"channelid": channel.Id, "userid": []string{users[0].Id, users[2].Id}}).
+		Exec()
+	require.NoError(t, err)
+
+	ownerIDs, err := th.Store.GetChannelOwnerIDs(channel.Id, th.User1.Id)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ownerIDs, []string{th.User1.Id, users[0].Id})
+}
+
 func TestSQLStore_GetStaleChannelsNone(t *testing.T) {
 	th := SetupHelper(t).SetupBasic(t)
 	defer th.TearDown()
@@ -223,6 +540,86 @@ func TestSQLStore_GetStaleChannelsNone(t *testing.T) {
 	assert.Empty(t, staleChannels)
 }
 
+func TestSQLStore_GetStaleChannelsLastActivity(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	monthAgo := model.GetMillisForTime(time.Now().AddDate(0, -1, 0))
+	twoMonthsAgo := model.GetMillisForTime(time.Now().AddDate(0, -2, 0))
+
+	channels, err := th.CreateChannels(4, "last-activity-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	// channel 0 - no posts
+	setTimestamps(t, th, "channels", channels[0].Id, yearAgo, yearAgo, 0)
+
+	// channel 1 - post edited two months ago
+	_, err = th.CreatePosts(1, th.User1.Id, channels[1].Id)
+	require.NoError(t, err)
+	setTimestamps(t, th, "channels", channels[1].Id, yearAgo, yearAgo, 0)
+	setTimestamps(t, th, "posts", channels[1].Id, yearAgo, twoMonthsAgo, 0)
+
+	// channel 2 - reaction removed a month ago
+	posts, err := th.CreatePosts(1, th.User1.Id, channels[2].Id)
+	require.NoError(t, err)
+	_, err = th.CreateReactions(posts, th.User1.Id)
+	require.NoError(t, err)
+	setTimestamps(t, th, "channels", channels[2].Id, yearAgo, yearAgo, 0)
+	setTimestamps(t, th, "posts", channels[2].Id, yearAgo, twoMonthsAgo, 0)
+	setTimestamps(t, th, "reactions", channels[2].Id, yearAgo, yearAgo, monthAgo)
+
+	// channel 3 - post edited two months ago, reaction with no update or delete timestamps
+	posts, err = th.CreatePosts(1, th.User1.Id, channels[3].Id)
+	require.NoError(t, err)
+	_, err = th.CreateReactions(posts, th.User1.Id)
+	require.NoError(t, err)
+	setTimestamps(t, th, "channels", channels[3].Id, yearAgo, yearAgo, 0)
+	setTimestamps(t, th, "posts", channels[3].Id, yearAgo, twoMonthsAgo, 0)
+	_, err = th.Store.builder.Update("reactions").
+		Set("updateat", nil).
+		Set("deleteat", nil).
+		Where(sq.Eq{"postid": posts[0].Id}).
+		Exec()
+	require.NoError(t, err)
+
+	opts := StaleChannelOpts{
+		AgeInDays:              7,
+		IncludeChannelTypeOpen: true,
+	}
+	staleChannels, _, err := th.Store.GetStaleChannels(opts, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, staleChannels, 4)
+
+	byID := make(map[string]*StaleChannel)
+	for _, ch := range staleChannels {
+		byID[ch.Id] = ch
+	}
+
+	assert.Equal(t, yearAgo, byID[channels[0].Id].LastActivityAt)
+	assert.Equal(t, ActivitySourceChannel, byID[channels[0].Id].LastActivitySource)
+	assert.Equal(t, twoMonthsAgo, byID[channels[1].Id].LastActivityAt)
+	assert.Equal(t, ActivitySourcePost, byID[channels[1].Id].LastActivitySource)
+	assert.Equal(t, monthAgo, byID[channels[2].Id].LastActivityAt)
+	assert.Equal(t, ActivitySourceReaction, byID[channels[2].Id].LastActivitySource)
+	assert.Equal(t, twoMonthsAgo, byID[channels[3].Id].LastActivityAt)
+	assert.Equal(t, ActivitySourcePost, byID[channels[3].Id].LastActivitySource)
+}
+
+func TestStaleChannelIdleDays(t *testing.T) {
+	now := time.Date(2022, 6, 10, 12, 0, 0, 0, time.UTC)
+	ch := &StaleChannel{Channel: &model.Channel{}}
+
+	ch.setLastActivity(model.GetMillisForTime(now.AddDate(0, 0, -400)), sql.NullInt64{}, sql.NullInt64{})
+	assert.Equal(t, ActivitySourceChannel, ch.LastActivitySource)
+	assert.Equal(t, 400, ch.IdleDays(now))
+
+	ch.setLastActivity(model.GetMillisForTime(now.AddDate(0, 0, -400)),
+		sql.NullInt64{Int64: model.GetMillisForTime(now.AddDate(0, 0, -30).Add(-time.Hour)), Valid: true},
+		sql.NullInt64{Int64: model.GetMillisForTime(now.AddDate(0, 0, -60)), Valid: true})
+	assert.Equal(t, ActivitySourcePost, ch.LastActivitySource)
+	assert.Equal(t, 30, ch.IdleDays(now))
+}
+
 func setTimestamps(t *testing.T, th *TestHelper, table string, channelID string, createAt, updateAt, deleteAt int64) {
 	query := th.Store.builder.Update(table)
 
@@ -259,7 +656,7 @@ func setTimestamps(t *testing.T, th *TestHelper, table string, channelID string,
 	t.Logf("setTimestamps for %s, %d rows affected.", table, rowsAffected)
 }
 
-func extractChannelIDs(channels []*model.Channel) []string {
+func extractChannelIDs(channels []*StaleChannel) []string {
 	ids := make([]string, 0, len(channels))
 	for _, ch := range channels {
 		ids = append(ids, ch.Id)
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var (
+	channelStateColumns = []string{"channelid", "firststaleat", "warningcount", "lastwarnedat", "archivedat", "archivedbyrun"}
+)
+
+// ChannelState tracks what the Channel Archiver has done to a channel across runs.
+type ChannelState struct {
+	ChannelID     string
+	FirstStaleAt  int64  // when the channel was first detected stale in its current stale period
+	WarningCount  int    // number of warnings posted to the channel
+	LastWarnedAt  int64  // when the most recent warning was posted
+	ArchivedAt    int64  // when the channel was archived by the Channel Archiver
+	ArchivedByRun string // ID of the run that archived the channel
+}
+
+// GetChannelState fetches the state for a channel. If the Channel Archiver has never acted on
+// the channel an empty state is returned.
+func (ss *SQLStore) GetChannelState(channelID string) (*ChannelState, error) {
+	query := ss.builder.Select(channelStateColumns...).
+		From(channelStateTable).
+		Where(sq.Eq{"channelid": channelID})
+
+	state := &ChannelState{}
+	err := query.QueryRow().Scan(&state.ChannelID, &state.FirstStaleAt, &state.WarningCount, &state.LastWarnedAt, &state.ArchivedAt, &state.ArchivedByRun)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &ChannelState{ChannelID: channelID}, nil
+	}
+	if err != nil {
+		ss.logger.Error("error fetching channel state", "channel_id", channelID, "err", err)
+		return nil, err
+	}
+	return state, nil
+}
+
+// SaveChannelState creates or replaces the state for a channel.
+func (ss *SQLStore) SaveChannelState(state *ChannelState) error {
+	values := []interface{}{state.ChannelID, state.FirstStaleAt, state.WarningCount, state.LastWarnedAt, state.ArchivedAt, state.ArchivedByRun}
+
+	if err := ss.upsert(channelStateTable, "channelid", channelStateColumns, values); err != nil {
+		ss.logger.Error("error saving channel state", "channel_id", state.ChannelID, "err", err)
+		return err
+	}
+	return nil
+}
+
+// ArchivedChannelOpts selects channels archived by the Channel Archiver. Empty fields are ignored.
+type ArchivedChannelOpts struct {
+	RunID          string // archived by this run
+	ArchivedAfter  int64  // archived at or after this time
+	ArchivedBefore int64  // archived before this time
+}
+
+// GetArchivedChannelStates fetches the state of channels archived by the Channel Archiver that
+// are still archived.
+func (ss *SQLStore) GetArchivedChannelStates(opts ArchivedChannelOpts) ([]*ChannelState, error) {
+	columns := make([]string, 0, len(channelStateColumns))
+	for _, col := range channelStateColumns {
+		columns = append(columns, "cs."+col)
+	}
+
+	query := ss.builder.Select(columns...).
+		From(channelStateTable+" as cs").
+		Join("channels as ch ON ch.id=cs.channelid").
+		Where(sq.Gt{"cs.archivedat": 0}).
+		Where(sq.Gt{"ch.deleteat": 0}).
+		OrderBy("cs.archivedat", "cs.channelid")
+
+	if opts.RunID != "" {
+		query = query.Where(sq.Eq{"cs.archivedbyrun": opts.RunID})
+	}
+	if opts.ArchivedAfter > 0 {
+		query = query.Where(sq.GtOrEq{"cs.archivedat": opts.ArchivedAfter})
+	}
+	if opts.ArchivedBefore > 0 {
+		query = query.Where(sq.Lt{"cs.archivedat": opts.ArchivedBefore})
+	}
+
+	rows, err := query.Query()
+	if err != nil {
+		ss.logger.Error("error fetching archived channel states", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := []*ChannelState{}
+	for rows.Next() {
+		state := &ChannelState{}
+		if err := rows.Scan(&state.ChannelID, &state.FirstStaleAt, &state.WarningCount, &state.LastWarnedAt, &state.ArchivedAt, &state.ArchivedByRun); err != nil {
+			ss.logger.Error("error scanning archived channel states", "err", err)
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
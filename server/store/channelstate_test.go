@@ -0,0 +1,101 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+func TestSQLStore_ChannelState(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	t.Run("missing state is empty", func(t *testing.T) {
+		state, err := th.Store.GetChannelState(th.Channel1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, &ChannelState{ChannelID: th.Channel1.Id}, state)
+	})
+
+	t.Run("save and update", func(t *testing.T) {
+		state := &ChannelState{
+			ChannelID:    th.Channel2.Id,
+			FirstStaleAt: weekAgo,
+			WarningCount: 1,
+			LastWarnedAt: weekAgo,
+		}
+		require.NoError(t, th.Store.SaveChannelState(state))
+
+		fetched, err := th.Store.GetChannelState(th.Channel2.Id)
+		require.NoError(t, err)
+		assert.Equal(t, state, fetched)
+
+		runID := model.NewId()
+		state.ArchivedAt = model.GetMillis()
+		state.ArchivedByRun = runID
+		require.NoError(t, th.Store.SaveChannelState(state))
+
+		fetched, err = th.Store.GetChannelState(th.Channel2.Id)
+		require.NoError(t, err)
+		assert.Equal(t, state, fetched)
+	})
+}
+
+func TestSQLStore_GetArchivedChannelStates(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	channels, err := th.CreateChannels(4, "archived-state-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	run1 := model.NewId()
+	run2 := model.NewId()
+
+	// channels 0,1 archived by run1 a year ago; channel 2 archived by run2 a week ago;
+	// channel 3 archived by run2 but since restored outside the plugin.
+	archived := []struct {
+		channel    *model.Channel
+		runID      string
+		archivedAt int64
+		deleteAt   int64
+	}{
+		{channels[0], run1, yearAgo, yearAgo},
+		{channels[1], run1, yearAgo, yearAgo},
+		{channels[2], run2, weekAgo, weekAgo},
+		{channels[3], run2, weekAgo, 0},
+	}
+	for _, a := range archived {
+		setTimestamps(t, th, "channels", a.channel.Id, yearAgo, yearAgo, a.deleteAt)
+		require.NoError(t, th.Store.SaveChannelState(&ChannelState{
+			ChannelID:     a.channel.Id,
+			ArchivedAt:    a.archivedAt,
+			ArchivedByRun: a.runID,
+		}))
+	}
+
+	extractStateIDs := func(states []*ChannelState) []string {
+		ids := make([]string, 0, len(states))
+		for _, s := range states {
+			ids = append(ids, s.ChannelID)
+		}
+		return ids
+	}
+
+	states, err := th.Store.GetArchivedChannelStates(ArchivedChannelOpts{RunID: run1})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, extractStateIDs(states), []string{channels[0].Id, channels[1].Id})
+
+	states, err = th.Store.GetArchivedChannelStates(ArchivedChannelOpts{RunID: run2})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, extractStateIDs(states), []string{channels[2].Id})
+
+	states, err = th.Store.GetArchivedChannelStates(ArchivedChannelOpts{ArchivedAfter: weekAgo - 1})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, extractStateIDs(states), []string{channels[2].Id})
+
+	states, err = th.Store.GetArchivedChannelStates(ArchivedChannelOpts{ArchivedBefore: weekAgo})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, extractStateIDs(states), []string{channels[0].Id, channels[1].Id})
+}
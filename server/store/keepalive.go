@@ -0,0 +1,61 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var (
+	keepAliveColumns = []string{"channelid", "userid", "createat", "expireat"}
+)
+
+// KeepAlive exempts a channel from being archived by the Channel Archiver.
+type KeepAlive struct {
+	ChannelID string
+	UserID    string // user who exempted the channel
+	CreateAt  int64
+	ExpireAt  int64 // zero means the exemption never expires
+}
+
+// GetKeepAlive fetches the keep-alive for a channel, or nil if the channel has none.
+func (ss *SQLStore) GetKeepAlive(channelID string) (*KeepAlive, error) {
+	query := ss.builder.Select(keepAliveColumns...).
+		From(keepAliveTable).
+		Where(sq.Eq{"channelid": channelID})
+
+	keepAlive := &KeepAlive{}
+	err := query.QueryRow().Scan(&keepAlive.ChannelID, &keepAlive.UserID, &keepAlive.CreateAt, &keepAlive.ExpireAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		ss.logger.Error("error fetching keep-alive", "channel_id", channelID, "err", err)
+		return nil, err
+	}
+	return keepAlive, nil
+}
+
+// SaveKeepAlive creates or replaces the keep-alive for a channel.
+func (ss *SQLStore) SaveKeepAlive(keepAlive *KeepAlive) error {
+	values := []interface{}{keepAlive.ChannelID, keepAlive.UserID, keepAlive.CreateAt, keepAlive.ExpireAt}
+
+	if err := ss.upsert(keepAliveTable, "channelid", keepAliveColumns, values); err != nil {
+		ss.logger.Error("error saving keep-alive", "channel_id", keepAlive.ChannelID, "err", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteKeepAlive removes the keep-alive for a channel, if any.
+func (ss *SQLStore) DeleteKeepAlive(channelID string) error {
+	_, err := ss.builder.Delete(keepAliveTable).
+		Where(sq.Eq{"channelid": channelID}).
+		Exec()
+	if err != nil {
+		ss.logger.Error("error deleting keep-alive", "channel_id", channelID, "err", err)
+		return err
+	}
+	return nil
+}
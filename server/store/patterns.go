@@ -0,0 +1,162 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Channel lists such as StaleChannelOpts.ExcludeChannels may mix three kinds of entries:
+//   - regular expressions, prefixed with 're:' (e.g. `re:^legal-`)
+//   - glob patterns, which contain '*' or '?' (e.g. `incident-*`)
+//   - exact channel names or IDs
+//
+// Patterns are matched case-insensitively against the channel name and display name.
+//
+// Regular expressions are run by the database, so they are limited to the POSIX extended
+// syntax that Go, Postgres and MySQL agree on: literals, '.', bracket expressions such as
+// `[a-z]` or `[[:digit:]]`, anchors, grouping, alternation, and the '*', '+', '?' and '{n,m}'
+// quantifiers. Backslash may only escape punctuation and may not be used inside brackets, and
+// Perl extensions such as `\d`, `(?i)` or lazy quantifiers are rejected.
+
+const regexPrefix = "re:"
+
+// isRegexPattern returns true if the entry is a regular expression.
+func isRegexPattern(entry string) bool {
+	return strings.HasPrefix(entry, regexPrefix)
+}
+
+// isGlobPattern returns true if the entry is a glob pattern.
+func isGlobPattern(entry string) bool {
+	return strings.ContainsAny(entry, "*?")
+}
+
+// globToRegex converts a glob pattern to an anchored regular expression.
+func globToRegex(glob string) string {
+	re := regexp.QuoteMeta(glob)
+	re = strings.ReplaceAll(re, `\*`, ".*")
+	re = strings.ReplaceAll(re, `\?`, ".")
+	return "^" + re + "$"
+}
+
+// splitPatterns separates exact channel names/IDs from patterns, returning the patterns as
+// regular expressions.
+func splitPatterns(entries []string) (exact []string, patterns []string) {
+	for _, entry := range entries {
+		switch {
+		case entry == "":
+		case isRegexPattern(entry):
+			patterns = append(patterns, strings.TrimPrefix(entry, regexPrefix))
+		case isGlobPattern(entry):
+			patterns = append(patterns, globToRegex(entry))
+		default:
+			exact = append(exact, entry)
+		}
+	}
+	return exact, patterns
+}
+
+// ValidateChannelPatterns checks that every regular expression in a channel list is valid and
+// uses only the syntax supported by all databases.
+func ValidateChannelPatterns(entries []string) error {
+	for _, entry := range entries {
+		if !isRegexPattern(entry) {
+			// channel names cannot contain these, so the entry was meant to be a regex.
+			if strings.HasPrefix(entry, "^") || strings.HasSuffix(entry, "$") {
+				return fmt.Errorf("invalid entry '%s': regular expressions must start with '%s'", entry, regexPrefix)
+			}
+			continue
+		}
+		pattern := strings.TrimPrefix(entry, regexPrefix)
+		if err := checkPortableRegex(pattern); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+		if _, err := regexp.CompilePOSIX(pattern); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// checkPortableRegex rejects escapes and constructs that the databases interpret differently
+// and that regexp.CompilePOSIX accepts.
+func checkPortableRegex(pattern string) error {
+	if pattern == "" {
+		return errors.New("empty pattern")
+	}
+
+	inBracket := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case inBracket && c == '\\':
+			return errors.New("backslash is not supported inside brackets")
+		case inBracket && c == '[' && i+1 < len(pattern) && pattern[i+1] == ':':
+			// character class such as [:digit:]
+			end := strings.Index(pattern[i+2:], ":]")
+			if end < 0 {
+				return errors.New("unterminated character class")
+			}
+			i += end + 3
+		case inBracket && c == ']':
+			inBracket = false
+		case inBracket:
+		case c == '[':
+			inBracket = true
+			// a leading '^' negates, and a ']' right after the opening bracket is a literal.
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				i++
+			}
+		case c == '\\':
+			if i+1 == len(pattern) {
+				return errors.New("trailing backslash")
+			}
+			next := rune(pattern[i+1])
+			if next > unicode.MaxASCII || unicode.IsLetter(next) || unicode.IsDigit(next) {
+				return fmt.Errorf("escape '\\%c' is not supported; only punctuation may be escaped", next)
+			}
+			i++
+		case strings.IndexByte("*+?}", c) >= 0 && i+1 < len(pattern) && strings.IndexByte("*+?", pattern[i+1]) >= 0:
+			// lazy or possessive in some databases, nested repetition in others.
+			return fmt.Errorf("quantifier '%c' cannot follow '%c'", pattern[i+1], c)
+		}
+	}
+	return nil
+}
+
+// matchChannels returns a condition that is true when a channel matches any of the entries by
+// ID, name, or pattern. Returns nil if there are no entries.
+func (ss *SQLStore) matchChannels(entries []string) sq.Sqlizer {
+	exact, patterns := splitPatterns(entries)
+
+	match := sq.Or{}
+	if len(exact) > 0 {
+		match = append(match, sq.Eq{"ch.id": exact}, sq.Eq{"ch.name": exact})
+	}
+	for _, pattern := range patterns {
+		match = append(match, ss.matchRegex("ch.name", pattern), ss.matchRegex("ch.displayname", pattern))
+	}
+
+	if len(match) == 0 {
+		return nil
+	}
+	return match
+}
+
+// matchRegex returns a case-insensitive regular expression match on the column.
+func (ss *SQLStore) matchRegex(column string, pattern string) sq.Sqlizer {
+	if ss.driverName == model.DatabaseDriverMysql {
+		// MySQL REGEXP follows the column collation, which is case-insensitive for Mattermost tables.
+		return sq.Expr(column+" REGEXP ?", pattern)
+	}
+	return sq.Expr(column+" ~* ?", pattern)
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitPatterns(t *testing.T) {
+	exact, patterns := splitPatterns([]string{"town-square", "incident-*", "re:^legal-", "", "ch?t", "re:archive$", "abcdefghijklmnopqrstuvwxyz"})
+	assert.Equal(t, []string{"town-square", "abcdefghijklmnopqrstuvwxyz"}, exact)
+	assert.Equal(t, []string{`^incident-.*$`, "^legal-", `^ch.t$`, "archive$"}, patterns)
+}
+
+func TestGlobToRegex(t *testing.T) {
+	tests := []struct {
+		glob    string
+		match   []string
+		noMatch []string
+	}{
+		{glob: "incident-*", match: []string{"incident-", "incident-123"}, noMatch: []string{"old-incident-1"}},
+		{glob: "*.tmp", match: []string{"a.tmp"}, noMatch: []string{"atmp", "a.tmp.old"}},
+		{glob: "team-?", match: []string{"team-a"}, noMatch: []string{"team-ab"}},
+	}
+
+	for _, tt := range tests {
+		re, err := regexp.Compile(globToRegex(tt.glob))
+		require.NoError(t, err, tt.glob)
+		for _, s := range tt.match {
+			assert.True(t, re.MatchString(s), "%s should match %s", tt.glob, s)
+		}
+		for _, s := range tt.noMatch {
+			assert.False(t, re.MatchString(s), "%s should not match %s", tt.glob, s)
+		}
+	}
+}
+
+func TestValidateChannelPatterns(t *testing.T) {
+	assert.NoError(t, ValidateChannelPatterns([]string{"town-square", "incident-*", "re:^legal-"}))
+	assert.NoError(t, ValidateChannelPatterns([]string{`re:^(inc|ops)-[[:digit:]]{2,4}\.old$`, "re:[]a-z]+", "re:[^-]x"}))
+
+	for _, entry := range []string{
+		"re:^legal-(", // does not compile
+		"^legal-",     // missing prefix
+		"archive$",    // missing prefix
+		"re:",
+		`re:^\d+`,
+		`re:\pL`,
+		`re:\bword`,
+		"re:(?i)legal",
+		"re:(?:a|b)",
+		"re:a*?",
+		`re:[\w]`,
+		`re:a\`,
+	} {
+		assert.Error(t, ValidateChannelPatterns([]string{entry}), entry)
+	}
+}
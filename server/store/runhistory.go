@@ -0,0 +1,130 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+const (
+	// TriggeredBySchedule is the RunHistory.TriggeredBy value for scheduled runs.
+	TriggeredBySchedule = "schedule"
+)
+
+var (
+	runHistoryColumns = []string{"runid", "policy", "triggeredby", "listonly", "startat", "endat", "stalecount",
+		"archivedcount", "warnedcount", "exitreason", "errormessage", "settings"}
+)
+
+// RunHistory records a Channel Archiver run.
+type RunHistory struct {
+	RunID         string
+	Policy        string // policy name for scheduled runs; empty for the default policy and manual runs
+	TriggeredBy   string // ID of the user who started the run, or TriggeredBySchedule
+	ListOnly      bool
+	StartAt       int64
+	EndAt         int64
+	StaleCount    int // number of stale channels found
+	ArchivedCount int
+	WarnedCount   int
+	ExitReason    string
+	ErrorMessage  string
+	Settings      string // snapshot of the options the run used
+}
+
+// SaveRunHistory creates or replaces the record of a run.
+func (ss *SQLStore) SaveRunHistory(run *RunHistory) error {
+	values := []interface{}{run.RunID, run.Policy, run.TriggeredBy, run.ListOnly, run.StartAt, run.EndAt, run.StaleCount,
+		run.ArchivedCount, run.WarnedCount, run.ExitReason, run.ErrorMessage, run.Settings}
+
+	if err := ss.upsert(runHistoryTable, "runid", runHistoryColumns, values); err != nil {
+		ss.logger.Error("error saving run history", "run_id", run.RunID, "err", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteRunHistoryBefore removes the records of runs that started before the given time, and
+// returns how many were removed.
+func (ss *SQLStore) DeleteRunHistoryBefore(startAt int64) (int64, error) {
+	result, err := ss.builder.Delete(runHistoryTable).
+		Where(sq.Lt{"startat": startAt}).
+		Exec()
+	if err != nil {
+		ss.logger.Error("error deleting run history", "before", startAt, "err", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetRunHistory fetches a page of run records, most recent first, and whether there are more.
+func (ss *SQLStore) GetRunHistory(page int, pageSize int) ([]*RunHistory, bool, error) {
+	query := ss.builder.Select(runHistoryColumns...).
+		From(runHistoryTable).
+		OrderBy("startat DESC", "runid")
+
+	if page > 0 {
+		query = query.Offset(uint64(page * pageSize))
+	}
+	if pageSize > 0 {
+		// N+1 to check if there's a next page for pagination
+		query = query.Limit(uint64(pageSize) + 1)
+	}
+
+	rows, err := query.Query()
+	if err != nil {
+		ss.logger.Error("error fetching run history", "err", err)
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	runs := []*RunHistory{}
+	for rows.Next() {
+		run, err := scanRunHistory(rows)
+		if err != nil {
+			ss.logger.Error("error scanning run history", "err", err)
+			return nil, false, err
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	var hasMore bool
+	if pageSize > 0 && len(runs) > pageSize {
+		hasMore = true
+		runs = runs[0:pageSize]
+	}
+	return runs, hasMore, nil
+}
+
+// GetRunHistoryByID fetches the record of a run. If there is no such run nil is returned.
+func (ss *SQLStore) GetRunHistoryByID(runID string) (*RunHistory, error) {
+	query := ss.builder.Select(runHistoryColumns...).
+		From(runHistoryTable).
+		Where(sq.Eq{"runid": runID})
+
+	run, err := scanRunHistory(query.QueryRow())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		ss.logger.Error("error fetching run history", "run_id", runID, "err", err)
+		return nil, err
+	}
+	return run, nil
+}
+
+func scanRunHistory(row sq.RowScanner) (*RunHistory, error) {
+	run := &RunHistory{}
+	var errorMessage, settings sql.NullString
+	if err := row.Scan(&run.RunID, &run.Policy, &run.TriggeredBy, &run.ListOnly, &run.StartAt, &run.EndAt, &run.StaleCount,
+		&run.ArchivedCount, &run.WarnedCount, &run.ExitReason, &errorMessage, &settings); err != nil {
+		return nil, err
+	}
+	run.ErrorMessage = errorMessage.String
+	run.Settings = settings.String
+	return run, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+func TestSQLStore_RunHistory(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	t.Run("missing run is nil", func(t *testing.T) {
+		run, err := th.Store.GetRunHistoryByID(model.NewId())
+		require.NoError(t, err)
+		assert.Nil(t, run)
+	})
+
+	runs := []*RunHistory{
+		{RunID: model.NewId(), TriggeredBy: TriggeredBySchedule, StartAt: yearAgo, EndAt: yearAgo + 1000, StaleCount: 3, ArchivedCount: 3, ExitReason: "completed normally", Settings: "{}"},
+		{RunID: model.NewId(), TriggeredBy: th.User1.Id, ListOnly: true, StartAt: weekAgo, EndAt: weekAgo + 1000, StaleCount: 5, ExitReason: "completed normally"},
+		{RunID: model.NewId(), Policy: "tmp", TriggeredBy: TriggeredBySchedule, StartAt: model.GetMillis(), ExitReason: "error", ErrorMessage: "cannot find team 'x'"},
+	}
+	for _, run := range runs {
+		require.NoError(t, th.Store.SaveRunHistory(run))
+	}
+
+	t.Run("get by ID", func(t *testing.T) {
+		for _, run := range runs {
+			fetched, err := th.Store.GetRunHistoryByID(run.RunID)
+			require.NoError(t, err)
+			assert.Equal(t, run, fetched)
+		}
+	})
+
+	t.Run("most recent first", func(t *testing.T) {
+		fetched, more, err := th.Store.GetRunHistory(0, 2)
+		require.NoError(t, err)
+		assert.True(t, more)
+		assert.Equal(t, []*RunHistory{runs[2], runs[1]}, fetched)
+
+		fetched, more, err = th.Store.GetRunHistory(1, 2)
+		require.NoError(t, err)
+		assert.False(t, more)
+		assert.Equal(t, []*RunHistory{runs[0]}, fetched)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		runs[0].ArchivedCount = 2
+		require.NoError(t, th.Store.SaveRunHistory(runs[0]))
+
+		fetched, err := th.Store.GetRunHistoryByID(runs[0].RunID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, fetched.ArchivedCount)
+	})
+
+	t.Run("delete before", func(t *testing.T) {
+		monthAgo := model.GetMillisForTime(time.Now().AddDate(0, -1, 0))
+		deleted, err := th.Store.DeleteRunHistoryBefore(monthAgo)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		fetched, more, err := th.Store.GetRunHistory(0, 10)
+		require.NoError(t, err)
+		assert.False(t, more)
+		assert.Equal(t, []*RunHistory{runs[2], runs[1]}, fetched)
+	})
+}
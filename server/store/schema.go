@@ -0,0 +1,87 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+	channelStateTable = "retention_channelstate"
+	keepAliveTable    = "retention_keepalive"
+	runHistoryTable   = "retention_runhistory"
+)
+
+// createTableStatements create the tables owned by this plugin. Each statement must be
+// idempotent and work on both Postgres and MySQL.
+var createTableStatements = []string{
+	`CREATE TABLE IF NOT EXISTS ` + channelStateTable + ` (
+		channelid VARCHAR(26) NOT NULL,
+		firststaleat BIGINT NOT NULL DEFAULT 0,
+		warningcount INT NOT NULL DEFAULT 0,
+		lastwarnedat BIGINT NOT NULL DEFAULT 0,
+		archivedat BIGINT NOT NULL DEFAULT 0,
+		archivedbyrun VARCHAR(26) NOT NULL DEFAULT '',
+		PRIMARY KEY (channelid)
+	)`,
+	`CREATE TABLE IF NOT EXISTS ` + keepAliveTable + ` (
+		channelid VARCHAR(26) NOT NULL,
+		userid VARCHAR(26) NOT NULL,
+		createat BIGINT NOT NULL,
+		expireat BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (channelid)
+	)`,
+	`CREATE TABLE IF NOT EXISTS ` + runHistoryTable + ` (
+		runid VARCHAR(26) NOT NULL,
+		policy VARCHAR(64) NOT NULL DEFAULT '',
+		triggeredby VARCHAR(26) NOT NULL DEFAULT '',
+		listonly BOOLEAN NOT NULL DEFAULT FALSE,
+		startat BIGINT NOT NULL,
+		endat BIGINT NOT NULL DEFAULT 0,
+		stalecount INT NOT NULL DEFAULT 0,
+		archivedcount INT NOT NULL DEFAULT 0,
+		warnedcount INT NOT NULL DEFAULT 0,
+		exitreason VARCHAR(64) NOT NULL DEFAULT '',
+		errormessage TEXT,
+		settings TEXT,
+		PRIMARY KEY (runid)
+	)`,
+}
+
+// createTables creates any plugin owned tables that do not exist yet.
+func (ss *SQLStore) createTables() error {
+	for _, stmt := range createTableStatements {
+		if _, err := ss.db.Exec(stmt); err != nil {
+			ss.logger.Error("error creating table", "err", err)
+			return fmt.Errorf("cannot create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// upsert inserts a row into the table or, if a row with the same key column value already
+// exists, updates the remaining columns.
+func (ss *SQLStore) upsert(table string, keyColumn string, columns []string, values []interface{}) error {
+	updates := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col == keyColumn {
+			continue
+		}
+		if ss.driverName == model.DatabaseDriverMysql {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", col, col))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+		}
+	}
+
+	query := ss.builder.Insert(table).Columns(columns...).Values(values...)
+	if ss.driverName == model.DatabaseDriverMysql {
+		query = query.Suffix("ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", "))
+	} else {
+		query = query.Suffix(fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", keyColumn, strings.Join(updates, ", ")))
+	}
+
+	_, err := query.Exec()
+	return err
+}
@@ -22,9 +22,10 @@ type Logger interface {
 }
 
 type SQLStore struct {
-	db      *sqlx.DB
-	builder sq.StatementBuilderType
-	logger  Logger
+	db         *sqlx.DB
+	builder    sq.StatementBuilderType
+	logger     Logger
+	driverName string
 }
 
 // New constructs a new instance of SQLStore.
@@ -48,9 +49,16 @@ func New(src SQLStoreSource, logger Logger) (*SQLStore, error) {
 
 	builder = builder.RunWith(db)
 
-	return &SQLStore{
-		db,
-		builder,
-		logger,
-	}, nil
+	store := &SQLStore{
+		db:         db,
+		builder:    builder,
+		logger:     logger,
+		driverName: src.DriverName(),
+	}
+
+	if err := store.createTables(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
 }
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -140,6 +141,10 @@ func (th *TestHelper) CreateChannels(num int, namePrefix string, userID string,
 	return channels, nil
 }
 
+func (th *TestHelper) CreateDirectChannel(user *model.User, otherUser *model.User) (*model.Channel, error) {
+	return th.mainHelper.Store.Channel().CreateDirectChannel(user, otherUser)
+}
+
 func (th *TestHelper) CreateUsers(num int, namePrefix string) ([]*model.User, error) {
 	var users []*model.User
 	for i := 0; i < num; i++ {
@@ -157,6 +162,50 @@ func (th *TestHelper) CreateUsers(num int, namePrefix string) ([]*model.User, er
 	return users, nil
 }
 
+func (th *TestHelper) DeactivateUsers(userIDs ...string) error {
+	for _, userID := range userIDs {
+		user, err := th.mainHelper.Store.User().Get(context.Background(), userID)
+		if err != nil {
+			return err
+		}
+		user.DeleteAt = model.GetMillis()
+		if _, err := th.mainHelper.Store.User().Update(user, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (th *TestHelper) CreateBot(username string) (*model.User, error) {
+	users, err := th.CreateUsers(1, username)
+	if err != nil {
+		return nil, err
+	}
+	bot := &model.Bot{
+		UserId:   users[0].Id,
+		Username: users[0].Username,
+		OwnerId:  th.User1.Id,
+	}
+	if _, err := th.mainHelper.Store.Bot().Save(bot); err != nil {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+func (th *TestHelper) AddChannelMembers(channelID string, userIDs ...string) error {
+	for _, userID := range userIDs {
+		member := &model.ChannelMember{
+			ChannelId:   channelID,
+			UserId:      userID,
+			NotifyProps: model.GetDefaultChannelNotifyProps(),
+		}
+		if _, err := th.mainHelper.Store.Channel().SaveMember(member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (th *TestHelper) CreatePosts(num int, userID string, channelID string) ([]*model.Post, error) {
 	var posts []*model.Post
 	for i := 0; i < num; i++ {
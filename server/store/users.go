@@ -0,0 +1,59 @@
+package store
+
+import (
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+// GetDeactivatedUsersWithMemberships fetches the IDs of users, other than bots, deactivated
+// before deactivatedBefore who still belong to a team or to an unarchived public or private
+// channel. Default channels are not counted, since only leaving the team removes them.
+//
+// IDs are returned in order, starting after afterID, so callers can page through them even
+// when some users cannot be cleaned up.
+func (ss *SQLStore) GetDeactivatedUsersWithMemberships(deactivatedBefore int64, afterID string, limit int) ([]string, error) {
+	teamMemberships := sq.Select("1").
+		From("teammembers as tm").
+		Where("tm.userid=u.id").
+		Where(sq.Eq{"tm.deleteat": 0})
+
+	channelMemberships := sq.Select("1").
+		From("channelmembers as cm").
+		Join("channels as ch ON ch.id=cm.channelid").
+		Where("cm.userid=u.id").
+		Where(sq.Eq{"ch.deleteat": 0, "ch.type": []string{string(model.ChannelTypeOpen), string(model.ChannelTypePrivate)}}).
+		Where(sq.NotEq{"ch.name": model.DefaultChannelName})
+
+	query := ss.builder.Select("u.id").
+		From("users as u").
+		LeftJoin("bots as b ON b.userid=u.id").
+		Where(sq.Eq{"b.userid": nil}).
+		Where(sq.Gt{"u.deleteat": 0}).
+		Where(sq.Lt{"u.deleteat": deactivatedBefore}).
+		Where(sq.Gt{"u.id": afterID}).
+		Where(sq.Or{sq.Expr("EXISTS (?)", teamMemberships), sq.Expr("EXISTS (?)", channelMemberships)}).
+		OrderBy("u.id")
+
+	if limit > 0 {
+		query = query.Limit(uint64(limit))
+	}
+
+	rows, err := query.Query()
+	if err != nil {
+		ss.logger.Error("error fetching deactivated users", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			ss.logger.Error("error scanning deactivated users", "err", err)
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+func TestSQLStore_GetDeactivatedUsersWithMemberships(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	// users 0,1 deactivated a year ago with memberships; user 2 deactivated a year ago and only
+	// a member of a default channel; user 3 deactivated today with memberships; user 4 active with memberships.
+	users, err := th.CreateUsers(5, "deactivated-test")
+	require.NoError(t, err)
+	bot, err := th.CreateBot("deactivated-test-bot")
+	require.NoError(t, err)
+
+	for _, userID := range []string{users[0].Id, users[1].Id, users[3].Id, users[4].Id, bot.Id} {
+		require.NoError(t, th.AddChannelMembers(th.Channel1.Id, userID))
+	}
+
+	teams, err := th.CreateTeams(1, "deactivated-test")
+	require.NoError(t, err)
+	defaultChannels, err := th.CreateChannels(1, "deactivated-test", users[2].Id, teams[0].Id)
+	require.NoError(t, err)
+	_, err = th.Store.builder.Update("channels").
+		Set("name", model.DefaultChannelName).
+		Where(sq.Eq{"id": defaultChannels[0].Id}).
+		Exec()
+	require.NoError(t, err)
+	require.NoError(t, th.AddChannelMembers(defaultChannels[0].Id, users[2].Id))
+	require.NoError(t, th.DeactivateUsers(users[0].Id, users[1].Id, users[2].Id, users[3].Id, bot.Id))
+
+	_, err = th.Store.builder.Update("users").
+		Set("deleteat", yearAgo).
+		Where(sq.Eq{"id": []string{users[0].Id, users[1].Id, users[2].Id, bot.Id}}).
+		Exec()
+	require.NoError(t, err)
+
+	userIDs, err := th.Store.GetDeactivatedUsersWithMemberships(weekAgo, "", 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{users[0].Id, users[1].Id}, userIDs)
+
+	// paging
+	first, err := th.Store.GetDeactivatedUsersWithMemberships(weekAgo, "", 1)
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+	second, err := th.Store.GetDeactivatedUsersWithMemberships(weekAgo, first[0], 1)
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.ElementsMatch(t, userIDs, append(first, second...))
+}